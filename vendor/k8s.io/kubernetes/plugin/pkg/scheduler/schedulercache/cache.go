@@ -0,0 +1,992 @@
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedulercache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+var (
+	cleanAssumedPeriod = 1 * time.Second
+)
+
+// podState keeps a pod and whether it's assumed, and if assumed, whether and
+// when it should expire.
+type podState struct {
+	pod *v1.Pod
+	// Used by assumedPod to determinate expiration.
+	// If deadline is nil, assumedPod will never expire on its own. It is up to
+	// the caller (e.g. ForgetPod) to reclaim it explicitly. This supports
+	// operators who want to drive eviction purely off Add/Remove events rather
+	// than a wall-clock TTL.
+	deadline *time.Time
+	// Used to block cache from expiring assumedPod if binding still runs
+	bindingFinished bool
+	// assumedTime records when finishBinding (re-)anchored deadline, so a
+	// Snapshot can report it. RestoreSnapshot carries it over unchanged; the
+	// remaining TTL is re-derived from deadline and the snapshot's TakenAt.
+	assumedTime time.Time
+}
+
+// nodeInfoListItem wraps a *NodeInfo in a doubly-linked list ordered by
+// recency of mutation, with the most recently added/updated node at
+// schedulerCache.headNode. UpdateNodeNameToInfoMap walks this list from the
+// head and stops as soon as it reaches a node that hasn't changed since the
+// caller's last call, instead of scanning every node in the cluster.
+type nodeInfoListItem struct {
+	name string
+	info *NodeInfo
+	next *nodeInfoListItem
+	prev *nodeInfoListItem
+}
+
+// New returns a Cache implementation. The cache's background
+// cleanupAssumedPods loop runs until ctx is done.
+func New(ctx context.Context, ttl time.Duration, observers ...CacheObserver) Cache {
+	cache := newSchedulerCache(ctx, ttl, cleanAssumedPeriod, observers...)
+	cache.run(ctx)
+	return cache
+}
+
+// NewWithStopChannel returns a Cache implementation whose background loop
+// terminates when stop is closed, for callers that haven't migrated to
+// context.Context yet.
+//
+// Deprecated: use New with a context.Context instead.
+func NewWithStopChannel(ttl time.Duration, stop <-chan struct{}, observers ...CacheObserver) Cache {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return New(ctx, ttl, observers...)
+}
+
+type schedulerCache struct {
+	// ctx is retained only so the periodic cleanupExpiredAssumedPods callback,
+	// which wait.Until invokes with no arguments, can derive a contextual
+	// logger and know when to stop.
+	ctx    context.Context
+	ttl    time.Duration
+	period time.Duration
+
+	// This mutex guards all fields within this cache struct.
+	mu sync.Mutex
+	// a set of assumed pod keys.
+	// The key could further be used to get an entry in podStates.
+	assumedPods map[string]bool
+	// a map from pod key (UID) to podState.
+	podStates map[string]*podState
+	// byNamespaceName maps a pod's "namespace/name" to the UID it's
+	// currently indexed under in podStates, so GetPodByName keeps working
+	// now that the primary index is UID-based. A namespace/name can only
+	// ever point at the UID of the pod that most recently claimed it.
+	byNamespaceName map[string]types.UID
+	// nodes holds every node's list item; headNode is the most recently
+	// mutated one. See nodeInfoListItem.
+	nodes    map[string]*nodeInfoListItem
+	headNode *nodeInfoListItem
+	// removedNodeNames accumulates node names removed from nodes since the
+	// last UpdateNodeNameToInfoMap call, so that call can prune them from
+	// the caller's infoMap without scanning it in full.
+	removedNodeNames []string
+	// podIndex is a reverse index of podStates, maintained alongside it so
+	// ByIndex can answer namespace/node/owner lookups without scanning every
+	// pod. See podIndex.
+	podIndex *podIndex
+
+	// A map from key to PodDisruptionBudget.
+	pdbs map[string]*v1beta1.PodDisruptionBudget
+	// pdbIndex is pdbs namespaced the same way podIndex indexes pods, so
+	// PDBsForPod only evaluates selectors against PDBs in the pod's own
+	// namespace. See pdbIndex.
+	pdbIndex *pdbIndex
+
+	// nodePDBs maps a node name to the PodDisruptionBudget capping
+	// simultaneous disruptions across every pod on that node, independent of
+	// workload labels. See AddNodePDB.
+	nodePDBs map[string]*v1beta1.PodDisruptionBudget
+
+	// observers are notified, in registration order, after every mutation
+	// path once state has been updated. See CacheObserver.
+	observers []CacheObserver
+}
+
+func newSchedulerCache(ctx context.Context, ttl, period time.Duration, observers ...CacheObserver) *schedulerCache {
+	return &schedulerCache{
+		ctx:    ctx,
+		ttl:    ttl,
+		period: period,
+
+		nodes:           make(map[string]*nodeInfoListItem),
+		assumedPods:     make(map[string]bool),
+		podStates:       make(map[string]*podState),
+		byNamespaceName: make(map[string]types.UID),
+		podIndex:        newPodIndex(podIndexers),
+		pdbs:            make(map[string]*v1beta1.PodDisruptionBudget),
+		pdbIndex:        newPDBIndex(),
+		nodePDBs:        make(map[string]*v1beta1.PodDisruptionBudget),
+		observers:       observers,
+	}
+}
+
+// nameKey returns the "namespace/name" key a pod is tracked under in
+// byNamespaceName.
+func nameKey(pod *v1.Pod) string {
+	return pod.Namespace + "/" + pod.Name
+}
+
+// indexPodName records pod's namespace/name as currently pointing at its UID.
+func (cache *schedulerCache) indexPodName(pod *v1.Pod) {
+	cache.byNamespaceName[nameKey(pod)] = pod.UID
+}
+
+// unindexPodName removes pod's namespace/name entry, but only if it's still
+// pointing at pod's own UID - a newer pod reusing the same namespace/name may
+// already have claimed it.
+func (cache *schedulerCache) unindexPodName(pod *v1.Pod) {
+	key := nameKey(pod)
+	if cache.byNamespaceName[key] == pod.UID {
+		delete(cache.byNamespaceName, key)
+	}
+}
+
+// indexPod files pod's key into podIndex under every registered index
+// value. Callers must hold cache.mu and call this at the same points they
+// call indexPodName, i.e. whenever a pod enters podStates.
+func (cache *schedulerCache) indexPod(pod *v1.Pod) {
+	cache.podIndex.add(string(pod.UID), pod)
+}
+
+// unindexPod reverses indexPod. Callers must hold cache.mu and call this at
+// the same points they call unindexPodName, i.e. whenever a pod leaves
+// podStates.
+func (cache *schedulerCache) unindexPod(pod *v1.Pod) {
+	cache.podIndex.remove(string(pod.UID), pod)
+}
+
+// nodeInfo returns the *NodeInfo for name, or nil if it isn't cached.
+func (cache *schedulerCache) nodeInfo(name string) *NodeInfo {
+	item, ok := cache.nodes[name]
+	if !ok {
+		return nil
+	}
+	return item.info
+}
+
+// ensureNodeInfo returns the existing *NodeInfo for name, creating an empty
+// one and inserting it into the list if this is the first time name is seen.
+func (cache *schedulerCache) ensureNodeInfo(name string) *NodeInfo {
+	item, ok := cache.nodes[name]
+	if !ok {
+		item = &nodeInfoListItem{name: name, info: NewNodeInfo()}
+		cache.nodes[name] = item
+		cache.moveNodeInfoToHead(name)
+	}
+	return item.info
+}
+
+// moveNodeInfoToHead moves name's list item to the head of the list,
+// inserting it if it isn't linked in yet. Callers must call this after every
+// mutation of a NodeInfo so the list stays ordered by recency.
+func (cache *schedulerCache) moveNodeInfoToHead(name string) {
+	item, ok := cache.nodes[name]
+	if !ok || cache.headNode == item {
+		return
+	}
+	if item.prev != nil {
+		item.prev.next = item.next
+	}
+	if item.next != nil {
+		item.next.prev = item.prev
+	}
+	if cache.headNode != nil {
+		cache.headNode.prev = item
+	}
+	item.next = cache.headNode
+	item.prev = nil
+	cache.headNode = item
+}
+
+// removeNodeInfoFromList unlinks name's list item, deletes it from nodes, and
+// records the name as a tombstone so a subsequent UpdateNodeNameToInfoMap can
+// prune it from the caller's infoMap.
+func (cache *schedulerCache) removeNodeInfoFromList(name string) {
+	item, ok := cache.nodes[name]
+	if !ok {
+		return
+	}
+	if item.prev != nil {
+		item.prev.next = item.next
+	} else {
+		cache.headNode = item.next
+	}
+	if item.next != nil {
+		item.next.prev = item.prev
+	}
+	delete(cache.nodes, name)
+	cache.removedNodeNames = append(cache.removedNodeNames, name)
+}
+
+// event builds the CacheEvent handed to observers for pod, capturing the
+// node's requested/allocatable resource as they stand right now and how many
+// pods are currently assumed. Callers must hold cache.mu.
+func (cache *schedulerCache) event(pod *v1.Pod) CacheEvent {
+	ev := CacheEvent{
+		Pod:         pod,
+		Node:        pod.Spec.NodeName,
+		AssumedPods: len(cache.assumedPods),
+	}
+	if n := cache.nodeInfo(pod.Spec.NodeName); n != nil {
+		ev.RequestedResource = *n.requestedResource
+		ev.AllocatableResource = *n.allocatableResource
+	}
+	return ev
+}
+
+// deadlineFor returns the deadline an assumed pod with the given assumedTime
+// should expire at, or nil if this cache is configured to never expire
+// assumed pods on its own (ttl == 0).
+func (cache *schedulerCache) deadlineFor(assumedTime time.Time) *time.Time {
+	if cache.ttl == 0 {
+		return nil
+	}
+	deadline := assumedTime.Add(cache.ttl)
+	return &deadline
+}
+
+func (cache *schedulerCache) run(ctx context.Context) {
+	go wait.Until(cache.cleanupExpiredAssumedPods, cache.period, ctx.Done())
+}
+
+func (cache *schedulerCache) cleanupExpiredAssumedPods() {
+	cache.cleanupAssumedPods(cache.ctx, time.Now())
+}
+
+// cleanupAssumedPods exists for making test deterministic by taking time as input argument.
+// It reports no metrics itself; each expired pod is passed to observers via OnExpire.
+func (cache *schedulerCache) cleanupAssumedPods(ctx context.Context, now time.Time) {
+	select {
+	case <-ctx.Done():
+		return
+	default:
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	logger := klog.FromContext(ctx)
+	// The size of assumedPods should be small
+	for key := range cache.assumedPods {
+		ps, ok := cache.podStates[key]
+		if !ok {
+			klog.Fatal("Key found in assumed set but not in podStates. Potentially a logical error.")
+		}
+		if !ps.bindingFinished {
+			continue
+		}
+		// deadline == nil means the pod was assumed with ttl == 0: it never
+		// expires on its own and must be reclaimed explicitly via ForgetPod.
+		if ps.deadline != nil && now.After(*ps.deadline) {
+			if err := cache.expirePod(ctx, key, ps); err != nil {
+				logger.Error(err, "ExpirePod failed", "pod", klog.KObj(ps.pod))
+			}
+		}
+	}
+}
+
+func (cache *schedulerCache) expirePod(ctx context.Context, key string, ps *podState) error {
+	if err := cache.removePod(ps.pod); err != nil {
+		return err
+	}
+	delete(cache.assumedPods, key)
+	delete(cache.podStates, key)
+	cache.unindexPodName(ps.pod)
+	cache.unindexPod(ps.pod)
+	ev := cache.event(ps.pod)
+	klog.FromContext(ctx).V(3).Info("Pod expired from cache", "pod", klog.KObj(ps.pod), "node", ps.pod.Spec.NodeName, "reason", "ttl")
+	for _, o := range cache.observers {
+		o.OnExpire(ev, "ttl")
+	}
+	return nil
+}
+
+func (cache *schedulerCache) AssumePod(ctx context.Context, pod *v1.Pod) error {
+	key, err := getPodKey(pod)
+	if err != nil {
+		return err
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if _, ok := cache.podStates[key]; ok {
+		return fmt.Errorf("pod %v is in the cache, so can't be assumed", key)
+	}
+
+	collisionErr := cache.addPod(pod)
+	ps := &podState{
+		pod: pod,
+	}
+	cache.podStates[key] = ps
+	cache.assumedPods[key] = true
+	cache.indexPodName(pod)
+	cache.indexPod(pod)
+	ev := cache.event(pod)
+	klog.FromContext(ctx).V(5).Info("Assumed pod in cache", "pod", klog.KObj(pod), "node", pod.Spec.NodeName)
+	for _, o := range cache.observers {
+		o.OnAssume(ev)
+	}
+	return collisionErr
+}
+
+func (cache *schedulerCache) FinishBinding(ctx context.Context, pod *v1.Pod) error {
+	return cache.finishBinding(ctx, pod, time.Now())
+}
+
+// finishBinding exists to make tests deterministic by taking time as input argument.
+func (cache *schedulerCache) finishBinding(ctx context.Context, pod *v1.Pod, now time.Time) error {
+	key, err := getPodKey(pod)
+	if err != nil {
+		return err
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	klog.FromContext(ctx).V(5).Info("Finished binding for pod, can be expired", "pod", klog.KObj(pod), "node", pod.Spec.NodeName)
+	currState, ok := cache.podStates[key]
+	if ok && cache.assumedPods[key] {
+		currState.bindingFinished = true
+		currState.assumedTime = now
+		currState.deadline = cache.deadlineFor(now)
+		ev := cache.event(pod)
+		for _, o := range cache.observers {
+			o.OnFinishBinding(ev)
+		}
+	}
+	return nil
+}
+
+func (cache *schedulerCache) ForgetPod(ctx context.Context, pod *v1.Pod) error {
+	key, err := getPodKey(pod)
+	if err != nil {
+		return err
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	currState, ok := cache.podStates[key]
+	if ok && currState.pod.Spec.NodeName != pod.Spec.NodeName {
+		return fmt.Errorf("pod %v was assumed on %v but assigned to %v", key, pod.Spec.NodeName, currState.pod.Spec.NodeName)
+	}
+
+	switch {
+	// Only assumed pod can be forgotten.
+	case ok && cache.assumedPods[key]:
+		if err := cache.removePod(pod); err != nil {
+			return err
+		}
+		delete(cache.assumedPods, key)
+		delete(cache.podStates, key)
+		cache.unindexPodName(pod)
+		cache.unindexPod(pod)
+		ev := cache.event(pod)
+		klog.FromContext(ctx).V(5).Info("Forgot assumed pod", "pod", klog.KObj(pod), "node", pod.Spec.NodeName)
+		for _, o := range cache.observers {
+			o.OnForget(ev)
+		}
+	default:
+		return fmt.Errorf("pod %v wasn't assumed so cannot be forgotten", key)
+	}
+	return nil
+}
+
+// addPod adds the given pod to the cache, and adds the given pod's resource
+// consumption to the node it's scheduled on. If the pod's placement collides
+// with the node's existing allocation (a reused HostPort, or a resource
+// request that pushes the node over its allocatable capacity), the addition
+// is still applied - callers observe the collision via the returned
+// *CollisionError instead of losing the event.
+func (cache *schedulerCache) addPod(pod *v1.Pod) error {
+	n := cache.ensureNodeInfo(pod.Spec.NodeName)
+	if n.isForeignPod(pod) {
+		// The scheduler is claiming a pod already accounted for as a foreign
+		// allocation (see AddForeignPod): promote it into n.Pods() instead of
+		// adding its resource usage a second time.
+		n.promoteForeignPod(pod)
+		cache.moveNodeInfoToHead(pod.Spec.NodeName)
+		return nil
+	}
+	conflicts := n.Conflicts(pod)
+	n.AddPod(pod)
+	cache.moveNodeInfoToHead(pod.Spec.NodeName)
+	if len(conflicts) == 0 {
+		return nil
+	}
+	for _, c := range conflicts {
+		collisionsTotal.WithLabelValues(string(c.Reason)).Inc()
+	}
+	return &CollisionError{Node: pod.Spec.NodeName, Conflicts: conflicts}
+}
+
+// removePod removes the given pod from the cache, and removes the given
+// pod's resource consumption from the node it's scheduled on.
+func (cache *schedulerCache) removePod(pod *v1.Pod) error {
+	n := cache.nodeInfo(pod.Spec.NodeName)
+	if n == nil {
+		return nil
+	}
+	if err := n.RemovePod(pod); err != nil {
+		return err
+	}
+	if len(n.pods) == 0 && len(n.foreignPods) == 0 && n.node == nil && n.nodePDB == nil {
+		cache.removeNodeInfoFromList(pod.Spec.NodeName)
+	} else {
+		cache.moveNodeInfoToHead(pod.Spec.NodeName)
+	}
+	return nil
+}
+
+// AddForeignPod records pod's resource usage against its node without adding
+// it to List/FilteredList or NodeInfo.Pods: used for workloads that consume
+// node capacity outside the scheduler's own AssumePod/AddPod flow, such as
+// DaemonSets, static pods, or pods bound by an external scheduler.
+func (cache *schedulerCache) AddForeignPod(ctx context.Context, pod *v1.Pod) error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	key, err := getPodKey(pod)
+	if err != nil {
+		return err
+	}
+	if _, ok := cache.podStates[key]; ok {
+		// Already tracked by the scheduler itself; nothing to do.
+		return nil
+	}
+	n := cache.ensureNodeInfo(pod.Spec.NodeName)
+	if err := n.AddForeignPod(pod); err != nil {
+		return err
+	}
+	cache.moveNodeInfoToHead(pod.Spec.NodeName)
+	klog.FromContext(ctx).V(5).Info("Added foreign pod to cache", "pod", klog.KObj(pod), "node", pod.Spec.NodeName)
+	return nil
+}
+
+// UpdateForeignPod removes oldPod's foreign allocation and adds newPod's.
+func (cache *schedulerCache) UpdateForeignPod(ctx context.Context, oldPod, newPod *v1.Pod) error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if n := cache.nodeInfo(oldPod.Spec.NodeName); n != nil {
+		if err := n.RemoveForeignPod(oldPod); err != nil {
+			return err
+		}
+		if len(n.pods) == 0 && len(n.foreignPods) == 0 && n.node == nil && n.nodePDB == nil {
+			cache.removeNodeInfoFromList(oldPod.Spec.NodeName)
+		} else {
+			cache.moveNodeInfoToHead(oldPod.Spec.NodeName)
+		}
+	}
+	n := cache.ensureNodeInfo(newPod.Spec.NodeName)
+	if err := n.AddForeignPod(newPod); err != nil {
+		return err
+	}
+	cache.moveNodeInfoToHead(newPod.Spec.NodeName)
+	klog.FromContext(ctx).V(5).Info("Updated foreign pod in cache", "pod", klog.KObj(newPod), "node", newPod.Spec.NodeName)
+	return nil
+}
+
+// RemoveForeignPod reverses AddForeignPod.
+func (cache *schedulerCache) RemoveForeignPod(ctx context.Context, pod *v1.Pod) error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	n := cache.nodeInfo(pod.Spec.NodeName)
+	if n == nil {
+		return fmt.Errorf("node %v is not found", pod.Spec.NodeName)
+	}
+	if err := n.RemoveForeignPod(pod); err != nil {
+		return err
+	}
+	if len(n.pods) == 0 && len(n.foreignPods) == 0 && n.node == nil && n.nodePDB == nil {
+		cache.removeNodeInfoFromList(pod.Spec.NodeName)
+	} else {
+		cache.moveNodeInfoToHead(pod.Spec.NodeName)
+	}
+	klog.FromContext(ctx).V(5).Info("Removed foreign pod from cache", "pod", klog.KObj(pod), "node", pod.Spec.NodeName)
+	return nil
+}
+
+// ForeignAllocations returns the UIDs of pods tracked on nodeName as foreign
+// allocations - pods whose resource usage counts against the node but that
+// aren't scheduler-owned, so don't appear in List/FilteredList or
+// NodeInfo.Pods. See AddForeignPod.
+func (cache *schedulerCache) ForeignAllocations(nodeName string) []types.UID {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	return cache.nodeInfo(nodeName).ForeignAllocations()
+}
+
+func (cache *schedulerCache) AddPod(ctx context.Context, pod *v1.Pod) error {
+	key, err := getPodKey(pod)
+	if err != nil {
+		return err
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	logger := klog.FromContext(ctx).WithValues("pod", klog.KObj(pod), "node", pod.Spec.NodeName)
+	currState, ok := cache.podStates[key]
+	switch {
+	case ok && cache.assumedPods[key]:
+		// The real, kubelet-observed pod may carry annotations, NodeName,
+		// status, or resource overrides that differ from the assumed copy,
+		// so replace it rather than leaving the stale assumed pod behind.
+		var collisionErr error
+		if currState.pod.Spec.NodeName != pod.Spec.NodeName {
+			// Bound to a different node than it was assumed on: move its
+			// accounting from the assumed node to the actual one.
+			if err := cache.removePod(currState.pod); err != nil {
+				return err
+			}
+			collisionErr = cache.addPod(pod)
+		} else if n := cache.nodeInfo(pod.Spec.NodeName); n != nil {
+			n.updatePodObject(pod)
+		}
+		cache.unindexPod(currState.pod)
+		currState.pod = pod
+		delete(cache.assumedPods, key)
+		currState.deadline = nil
+		cache.indexPodName(pod)
+		cache.indexPod(pod)
+		ev := cache.event(pod)
+		logger.V(5).Info("Confirmed assumed pod in cache")
+		for _, o := range cache.observers {
+			o.OnAdd(ev)
+		}
+		return collisionErr
+	case !ok:
+		// Pod was expired. We should add it back.
+		collisionErr := cache.addPod(pod)
+		ps := &podState{
+			pod: pod,
+		}
+		cache.podStates[key] = ps
+		cache.indexPodName(pod)
+		cache.indexPod(pod)
+		ev := cache.event(pod)
+		logger.V(5).Info("Added pod to cache")
+		for _, o := range cache.observers {
+			o.OnAdd(ev)
+		}
+		return collisionErr
+	default:
+		return fmt.Errorf("pod %v was already in added state", key)
+	}
+}
+
+func (cache *schedulerCache) UpdatePod(ctx context.Context, oldPod, newPod *v1.Pod) error {
+	oldKey, err := getPodKey(oldPod)
+	if err != nil {
+		return err
+	}
+	newKey, err := getPodKey(newPod)
+	if err != nil {
+		return err
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	currState, ok := cache.podStates[oldKey]
+	if !ok {
+		return fmt.Errorf("pod %v is not added to scheduler cache, so cannot be updated", oldKey)
+	}
+	if cache.assumedPods[oldKey] {
+		return fmt.Errorf("assumed pod %v should not be updated", oldKey)
+	}
+
+	var collisionErr *CollisionError
+	if err := cache.updatePod(oldPod, newPod); err != nil {
+		// A *CollisionError from updatePod's addPod half means newPod's
+		// placement was still applied to the node, so the podStates/index
+		// bookkeeping below must still run - only a genuine failure (e.g.
+		// removePod's half not finding the old pod) should abort early,
+		// consistent with AddPod's handling of the same error type.
+		var ok bool
+		if collisionErr, ok = err.(*CollisionError); !ok {
+			return err
+		}
+	}
+	// oldPod and newPod normally share a UID - the common case is an
+	// informer delivering a resync of the same pod. But if the caller's
+	// old/new pair have the same namespace/name and different UIDs (e.g. the
+	// old pod was deleted and a new one recreated before the cache noticed),
+	// this is really a delete+add: move the podStates entry to newPod's key
+	// instead of leaving it registered under the stale UID.
+	if oldKey != newKey {
+		delete(cache.podStates, oldKey)
+		cache.podStates[newKey] = currState
+	}
+	cache.unindexPod(currState.pod)
+	currState.pod = newPod
+	cache.indexPodName(newPod)
+	cache.indexPod(newPod)
+	ev := cache.event(newPod)
+	klog.FromContext(ctx).V(5).Info("Updated pod in cache", "pod", klog.KObj(newPod), "node", newPod.Spec.NodeName)
+	for _, o := range cache.observers {
+		o.OnUpdate(oldPod, ev)
+	}
+	if collisionErr != nil {
+		return collisionErr
+	}
+	return nil
+}
+
+func (cache *schedulerCache) updatePod(oldPod, newPod *v1.Pod) error {
+	if err := cache.removePod(oldPod); err != nil {
+		return err
+	}
+	return cache.addPod(newPod)
+}
+
+func (cache *schedulerCache) RemovePod(ctx context.Context, pod *v1.Pod) error {
+	key, err := getPodKey(pod)
+	if err != nil {
+		return err
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	currState, ok := cache.podStates[key]
+	if !ok {
+		return fmt.Errorf("pod %v is not found in scheduler cache, so cannot be removed from it", key)
+	}
+	if currState.pod.Spec.NodeName != pod.Spec.NodeName {
+		return fmt.Errorf("pod %v was assumed on %v but assigned to %v", key, pod.Spec.NodeName, currState.pod.Spec.NodeName)
+	}
+
+	if err := cache.removePod(currState.pod); err != nil {
+		return err
+	}
+	delete(cache.podStates, key)
+	cache.unindexPodName(currState.pod)
+	cache.unindexPod(currState.pod)
+	ev := cache.event(pod)
+	klog.FromContext(ctx).V(5).Info("Removed pod from cache", "pod", klog.KObj(pod), "node", pod.Spec.NodeName)
+	for _, o := range cache.observers {
+		o.OnRemove(ev)
+	}
+	return nil
+}
+
+func (cache *schedulerCache) IsAssumedPod(pod *v1.Pod) (bool, error) {
+	key, err := getPodKey(pod)
+	if err != nil {
+		return false, err
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	return cache.assumedPods[key], nil
+}
+
+func (cache *schedulerCache) GetPod(pod *v1.Pod) (*v1.Pod, error) {
+	key, err := getPodKey(pod)
+	if err != nil {
+		return nil, err
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	podState, ok := cache.podStates[key]
+	if !ok {
+		return nil, fmt.Errorf("pod %v does not exist in scheduler cache", key)
+	}
+
+	return podState.pod, nil
+}
+
+func (cache *schedulerCache) GetPodByName(namespace, name string) (*v1.Pod, error) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	uid, ok := cache.byNamespaceName[namespace+"/"+name]
+	if !ok {
+		return nil, fmt.Errorf("pod %v/%v does not exist in scheduler cache", namespace, name)
+	}
+	podState, ok := cache.podStates[string(uid)]
+	if !ok {
+		return nil, fmt.Errorf("pod %v/%v does not exist in scheduler cache", namespace, name)
+	}
+	return podState.pod, nil
+}
+
+func (cache *schedulerCache) AddNode(ctx context.Context, node *v1.Node) error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	n := cache.ensureNodeInfo(node.Name)
+	err := n.SetNode(node)
+	cache.moveNodeInfoToHead(node.Name)
+	klog.FromContext(ctx).V(3).Info("Added node to cache", "node", node.Name)
+	return err
+}
+
+func (cache *schedulerCache) UpdateNode(ctx context.Context, oldNode, newNode *v1.Node) error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	n := cache.ensureNodeInfo(newNode.Name)
+	err := n.SetNode(newNode)
+	cache.moveNodeInfoToHead(newNode.Name)
+	klog.FromContext(ctx).V(3).Info("Updated node in cache", "node", newNode.Name)
+	return err
+}
+
+func (cache *schedulerCache) RemoveNode(ctx context.Context, node *v1.Node) error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	n := cache.nodeInfo(node.Name)
+	if n == nil {
+		return fmt.Errorf("node %v is not found", node.Name)
+	}
+	if err := n.RemoveNode(node); err != nil {
+		return err
+	}
+	// We remove NodeInfo for this node only if there aren't any pods on this node.
+	// We can't do it unconditionally, because notifications about pods are delivered
+	// in a different watch, and thus can potentially be observed later, even though
+	// they happened before node removal.
+	if len(n.pods) == 0 && len(n.foreignPods) == 0 && n.node == nil && n.nodePDB == nil {
+		cache.removeNodeInfoFromList(node.Name)
+	} else {
+		cache.moveNodeInfoToHead(node.Name)
+	}
+	klog.FromContext(ctx).V(3).Info("Removed node from cache", "node", node.Name)
+	return nil
+}
+
+// UpdateNodeNameToInfoMap updates infoMap to the current contents of the
+// cache. infoMap is treated as the caller's previous snapshot: nodes are only
+// re-cloned if their generation has advanced since infoMap was last updated,
+// and the walk from the most-recently-mutated node stops as soon as it
+// reaches one that hasn't changed, since every node behind it in the list is
+// guaranteed to be unchanged too. Nodes removed from the cache since the last
+// call are pruned from infoMap via the tombstone list instead of scanning
+// infoMap in full.
+func (cache *schedulerCache) UpdateNodeNameToInfoMap(infoMap map[string]*NodeInfo) error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	// Prune tombstoned deletions before re-populating from the list, so a
+	// node removed and then re-added with the same name since the last call
+	// isn't deleted right back out after being freshly cloned below.
+	for _, name := range cache.removedNodeNames {
+		delete(infoMap, name)
+	}
+	cache.removedNodeNames = nil
+	for item := cache.headNode; item != nil; item = item.next {
+		if current, ok := infoMap[item.name]; ok && current.generation == item.info.generation {
+			// This node, and everything behind it in the list, hasn't
+			// changed since infoMap was last updated: every mutation moves
+			// its node to the head, so anything further back was mutated
+			// longer ago than this unchanged node.
+			break
+		}
+		infoMap[item.name] = item.info.Clone()
+	}
+	return nil
+}
+
+func (cache *schedulerCache) List(selector labels.Selector) ([]*v1.Pod, error) {
+	return cache.FilteredList(func(pod *v1.Pod) bool { return true }, selector)
+}
+
+func (cache *schedulerCache) FilteredList(podFilter PodFilter, selector labels.Selector) ([]*v1.Pod, error) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	// podFilter is expected to return true for most or all of the pods. We
+	// size the slice as an optimization.
+	pods := make([]*v1.Pod, 0, len(cache.podStates))
+	for _, value := range cache.podStates {
+		if podFilter(value.pod) && selector.Matches(labels.Set(value.pod.Labels)) {
+			pods = append(pods, value.pod)
+		}
+	}
+	return pods, nil
+}
+
+// ByIndex returns the cached pods filed under key for the named index (one
+// of ByNamespaceIndex, ByNodeNameIndex, ByOwnerUIDIndex), without scanning
+// podStates. See podIndex.
+func (cache *schedulerCache) ByIndex(indexName, key string) ([]*v1.Pod, error) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	uids, err := cache.podIndex.keys(indexName, key)
+	if err != nil {
+		return nil, err
+	}
+	pods := make([]*v1.Pod, 0, len(uids))
+	for uid := range uids {
+		if ps, ok := cache.podStates[uid]; ok {
+			pods = append(pods, ps.pod)
+		}
+	}
+	return pods, nil
+}
+
+func (cache *schedulerCache) AddPDB(ctx context.Context, pdb *v1beta1.PodDisruptionBudget) error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	key, err := getPDBKey(pdb)
+	if err != nil {
+		return err
+	}
+	cache.pdbs[key] = pdb
+	cache.pdbIndex.add(key, pdb)
+	klog.FromContext(ctx).V(5).Info("Added PDB to cache", "podDisruptionBudget", key)
+	return nil
+}
+
+func (cache *schedulerCache) UpdatePDB(ctx context.Context, oldPDB, newPDB *v1beta1.PodDisruptionBudget) error {
+	return cache.AddPDB(ctx, newPDB)
+}
+
+func (cache *schedulerCache) RemovePDB(ctx context.Context, pdb *v1beta1.PodDisruptionBudget) error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	key, err := getPDBKey(pdb)
+	if err != nil {
+		return err
+	}
+	delete(cache.pdbs, key)
+	cache.pdbIndex.remove(key, pdb)
+	klog.FromContext(ctx).V(5).Info("Removed PDB from cache", "podDisruptionBudget", key)
+	return nil
+}
+
+func (cache *schedulerCache) ListPDBs(selector labels.Selector) ([]*v1beta1.PodDisruptionBudget, error) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	var pdbs []*v1beta1.PodDisruptionBudget
+	for _, pdb := range cache.pdbs {
+		if selector.Matches(labels.Set(pdb.Labels)) {
+			pdbs = append(pdbs, pdb)
+		}
+	}
+	return pdbs, nil
+}
+
+// PDBsForPod returns the PodDisruptionBudgets whose selector matches pod,
+// evaluating selectors only against PDBs already known to be in pod's
+// namespace via pdbIndex instead of walking every cached PDB.
+func (cache *schedulerCache) PDBsForPod(pod *v1.Pod) ([]*v1beta1.PodDisruptionBudget, error) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	keys := cache.pdbIndex.keys(pod.Namespace)
+	var pdbs []*v1beta1.PodDisruptionBudget
+	for key := range keys {
+		pdb, ok := cache.pdbs[key]
+		if !ok {
+			continue
+		}
+		selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector on PodDisruptionBudget %v: %v", key, err)
+		}
+		if selector.Matches(labels.Set(pod.Labels)) {
+			pdbs = append(pdbs, pdb)
+		}
+	}
+	return pdbs, nil
+}
+
+func getPDBKey(pdb *v1beta1.PodDisruptionBudget) (string, error) {
+	return pdb.Namespace + "/" + pdb.Name, nil
+}
+
+// AddNodePDB registers pdb as the node PodDisruptionBudget for nodeName,
+// replacing any node PDB previously registered for it.
+func (cache *schedulerCache) AddNodePDB(ctx context.Context, nodeName string, pdb *v1beta1.PodDisruptionBudget) error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.nodePDBs[nodeName] = pdb
+	cache.ensureNodeInfo(nodeName).SetNodePDB(pdb)
+	cache.moveNodeInfoToHead(nodeName)
+	klog.FromContext(ctx).V(5).Info("Added node PDB to cache", "node", nodeName, "podDisruptionBudget", klog.KObj(pdb))
+	return nil
+}
+
+// UpdateNodePDB replaces the node PodDisruptionBudget registered for
+// nodeName.
+func (cache *schedulerCache) UpdateNodePDB(ctx context.Context, nodeName string, pdb *v1beta1.PodDisruptionBudget) error {
+	return cache.AddNodePDB(ctx, nodeName, pdb)
+}
+
+// RemoveNodePDB clears the node PodDisruptionBudget registered for nodeName,
+// if any.
+func (cache *schedulerCache) RemoveNodePDB(ctx context.Context, nodeName string) error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	delete(cache.nodePDBs, nodeName)
+	if n := cache.nodeInfo(nodeName); n != nil {
+		n.SetNodePDB(nil)
+		cache.moveNodeInfoToHead(nodeName)
+	}
+	klog.FromContext(ctx).V(5).Info("Removed node PDB from cache", "node", nodeName)
+	return nil
+}
+
+// NodePDBsForNode returns the node PodDisruptionBudget registered for
+// nodeName, or nil if it has none. See AddNodePDB.
+func (cache *schedulerCache) NodePDBsForNode(nodeName string) *v1beta1.PodDisruptionBudget {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	return cache.nodePDBs[nodeName]
+}
+
+// AllowedDisruptions returns how many more pods may be evicted from nodeName
+// without violating its node PodDisruptionBudget. See
+// NodeInfo.AllowedDisruptions.
+func (cache *schedulerCache) AllowedDisruptions(nodeName string) int {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	return cache.nodeInfo(nodeName).AllowedDisruptions()
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package schedulercache
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"strings"
@@ -34,6 +35,13 @@ import (
 	schedutil "k8s.io/kubernetes/plugin/pkg/scheduler/util"
 )
 
+// testContext returns the context.Context cache calls in this package's
+// tests are made with. It's a plain context.Background() rather than
+// t.Context() so these tests keep working on toolchains older than Go 1.24.
+func testContext() context.Context {
+	return context.Background()
+}
+
 func deepEqualWithoutGeneration(t *testing.T, testcase int, actual, expected *NodeInfo) {
 	// Ignore generation field.
 	if actual != nil {
@@ -158,21 +166,21 @@ func TestAssumePodScheduled(t *testing.T) {
 	}
 
 	for i, tt := range tests {
-		cache := newSchedulerCache(time.Second, time.Second, nil)
+		cache := newSchedulerCache(testContext(), time.Second, time.Second)
 		for _, pod := range tt.pods {
-			if err := cache.AssumePod(pod); err != nil {
+			if err := cache.AssumePod(testContext(), pod); err != nil {
 				t.Fatalf("AssumePod failed: %v", err)
 			}
 		}
-		n := cache.nodes[nodeName]
+		n := cache.nodeInfo(nodeName)
 		deepEqualWithoutGeneration(t, i, n, tt.wNodeInfo)
 
 		for _, pod := range tt.pods {
-			if err := cache.ForgetPod(pod); err != nil {
+			if err := cache.ForgetPod(testContext(), pod); err != nil {
 				t.Fatalf("ForgetPod failed: %v", err)
 			}
 		}
-		if cache.nodes[nodeName] != nil {
+		if cache.nodeInfo(nodeName) != nil {
 			t.Errorf("NodeInfo should be cleaned for %s", nodeName)
 		}
 	}
@@ -184,10 +192,10 @@ type testExpirePodStruct struct {
 }
 
 func assumeAndFinishBinding(cache *schedulerCache, pod *v1.Pod, assumedTime time.Time) error {
-	if err := cache.AssumePod(pod); err != nil {
+	if err := cache.AssumePod(testContext(), pod); err != nil {
 		return err
 	}
-	return cache.finishBinding(pod, assumedTime)
+	return cache.finishBinding(testContext(), pod, assumedTime)
 }
 
 // TestExpirePod tests that assumed pods will be removed if expired.
@@ -233,7 +241,7 @@ func TestExpirePod(t *testing.T) {
 	}}
 
 	for i, tt := range tests {
-		cache := newSchedulerCache(ttl, time.Second, nil)
+		cache := newSchedulerCache(testContext(), ttl, time.Second)
 
 		for _, pod := range tt.pods {
 			if err := assumeAndFinishBinding(cache, pod.pod, pod.assumedTime); err != nil {
@@ -241,8 +249,8 @@ func TestExpirePod(t *testing.T) {
 			}
 		}
 		// pods that have assumedTime + ttl < cleanupTime will get expired and removed
-		cache.cleanupAssumedPods(tt.cleanupTime)
-		n := cache.nodes[nodeName]
+		cache.cleanupAssumedPods(testContext(), tt.cleanupTime)
+		n := cache.nodeInfo(nodeName)
 		deepEqualWithoutGeneration(t, i, n, tt.wNodeInfo)
 	}
 }
@@ -282,24 +290,58 @@ func TestAddPodWillConfirm(t *testing.T) {
 	}}
 
 	for i, tt := range tests {
-		cache := newSchedulerCache(ttl, time.Second, nil)
+		cache := newSchedulerCache(testContext(), ttl, time.Second)
 		for _, podToAssume := range tt.podsToAssume {
 			if err := assumeAndFinishBinding(cache, podToAssume, now); err != nil {
 				t.Fatalf("assumePod failed: %v", err)
 			}
 		}
 		for _, podToAdd := range tt.podsToAdd {
-			if err := cache.AddPod(podToAdd); err != nil {
+			if err := cache.AddPod(testContext(), podToAdd); err != nil {
 				t.Fatalf("AddPod failed: %v", err)
 			}
 		}
-		cache.cleanupAssumedPods(now.Add(2 * ttl))
+		cache.cleanupAssumedPods(testContext(), now.Add(2 * ttl))
 		// check after expiration. confirmed pods shouldn't be expired.
-		n := cache.nodes[nodeName]
+		n := cache.nodeInfo(nodeName)
 		deepEqualWithoutGeneration(t, i, n, tt.wNodeInfo)
 	}
 }
 
+// TestAddPodAlsoUpdatesPodInfo tests that confirming an assumed pod via
+// AddPod replaces the stored pod object, so GetPod reflects the
+// kubelet-observed pod rather than the stale assumed copy.
+func TestAddPodAlsoUpdatesPodInfo(t *testing.T) {
+	nodeName := "node"
+	now := time.Now()
+	ttl := 10 * time.Second
+
+	assumed := makeBasePod(t, nodeName, "test-1", "100m", "500", "", []v1.ContainerPort{{HostIP: "127.0.0.1", HostPort: 80, Protocol: "TCP"}})
+	added := assumed.DeepCopy()
+	added.Annotations = map[string]string{"confirmed-by": "kubelet"}
+
+	cache := newSchedulerCache(testContext(), ttl, time.Second)
+	if err := assumeAndFinishBinding(cache, assumed, now); err != nil {
+		t.Fatalf("assumePod failed: %v", err)
+	}
+	if err := cache.AddPod(testContext(), added); err != nil {
+		t.Fatalf("AddPod failed: %v", err)
+	}
+
+	got, err := cache.GetPod(assumed)
+	if err != nil {
+		t.Fatalf("GetPod failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, added) {
+		t.Errorf("GetPod returned the stale assumed pod, want the Add()ed one: got=%v, want=%v", got, added)
+	}
+
+	n := cache.nodeInfo(nodeName)
+	if len(n.pods) != 1 || !reflect.DeepEqual(n.pods[0], added) {
+		t.Errorf("NodeInfo.Pods() returned the stale assumed pod, want the Add()ed one: got=%v, want=[%v]", n.pods, added)
+	}
+}
+
 // TestAddPodWillReplaceAssumed tests that a pod being Add()ed will replace any assumed pod.
 func TestAddPodWillReplaceAssumed(t *testing.T) {
 	now := time.Now()
@@ -338,25 +380,25 @@ func TestAddPodWillReplaceAssumed(t *testing.T) {
 	}}
 
 	for i, tt := range tests {
-		cache := newSchedulerCache(ttl, time.Second, nil)
+		cache := newSchedulerCache(testContext(), ttl, time.Second)
 		for _, podToAssume := range tt.podsToAssume {
 			if err := assumeAndFinishBinding(cache, podToAssume, now); err != nil {
 				t.Fatalf("assumePod failed: %v", err)
 			}
 		}
 		for _, podToAdd := range tt.podsToAdd {
-			if err := cache.AddPod(podToAdd); err != nil {
+			if err := cache.AddPod(testContext(), podToAdd); err != nil {
 				t.Fatalf("AddPod failed: %v", err)
 			}
 		}
 		for _, podToUpdate := range tt.podsToUpdate {
-			if err := cache.UpdatePod(podToUpdate[0], podToUpdate[1]); err != nil {
+			if err := cache.UpdatePod(testContext(), podToUpdate[0], podToUpdate[1]); err != nil {
 				t.Fatalf("UpdatePod failed: %v", err)
 			}
 		}
 		for nodeName, expected := range tt.wNodeInfo {
 			t.Log(nodeName)
-			n := cache.nodes[nodeName]
+			n := cache.nodeInfo(nodeName)
 			deepEqualWithoutGeneration(t, i, n, expected)
 		}
 	}
@@ -390,21 +432,21 @@ func TestAddPodAfterExpiration(t *testing.T) {
 
 	now := time.Now()
 	for i, tt := range tests {
-		cache := newSchedulerCache(ttl, time.Second, nil)
+		cache := newSchedulerCache(testContext(), ttl, time.Second)
 		if err := assumeAndFinishBinding(cache, tt.pod, now); err != nil {
 			t.Fatalf("assumePod failed: %v", err)
 		}
-		cache.cleanupAssumedPods(now.Add(2 * ttl))
+		cache.cleanupAssumedPods(testContext(), now.Add(2 * ttl))
 		// It should be expired and removed.
-		n := cache.nodes[nodeName]
+		n := cache.nodeInfo(nodeName)
 		if n != nil {
 			t.Errorf("#%d: expecting nil node info, but get=%v", i, n)
 		}
-		if err := cache.AddPod(tt.pod); err != nil {
+		if err := cache.AddPod(testContext(), tt.pod); err != nil {
 			t.Fatalf("AddPod failed: %v", err)
 		}
 		// check after expiration. confirmed pods shouldn't be expired.
-		n = cache.nodes[nodeName]
+		n = cache.nodeInfo(nodeName)
 		deepEqualWithoutGeneration(t, i, n, tt.wNodeInfo)
 	}
 }
@@ -454,9 +496,9 @@ func TestUpdatePod(t *testing.T) {
 	}}
 
 	for _, tt := range tests {
-		cache := newSchedulerCache(ttl, time.Second, nil)
+		cache := newSchedulerCache(testContext(), ttl, time.Second)
 		for _, podToAdd := range tt.podsToAdd {
-			if err := cache.AddPod(podToAdd); err != nil {
+			if err := cache.AddPod(testContext(), podToAdd); err != nil {
 				t.Fatalf("AddPod failed: %v", err)
 			}
 		}
@@ -465,16 +507,65 @@ func TestUpdatePod(t *testing.T) {
 			if i == 0 {
 				continue
 			}
-			if err := cache.UpdatePod(tt.podsToUpdate[i-1], tt.podsToUpdate[i]); err != nil {
+			if err := cache.UpdatePod(testContext(), tt.podsToUpdate[i-1], tt.podsToUpdate[i]); err != nil {
 				t.Fatalf("UpdatePod failed: %v", err)
 			}
 			// check after expiration. confirmed pods shouldn't be expired.
-			n := cache.nodes[nodeName]
+			n := cache.nodeInfo(nodeName)
 			deepEqualWithoutGeneration(t, i, n, tt.wNodeInfo[i-1])
 		}
 	}
 }
 
+// TestUpdatePodWithCollision tests that UpdatePod still applies newPod's
+// placement and keeps podStates/GetPod/the indices in sync with it even when
+// the update collides with the node's existing allocation - mirroring how
+// AddPod treats a *CollisionError as non-fatal.
+func TestUpdatePodWithCollision(t *testing.T) {
+	nodeName := "node"
+	ttl := 10 * time.Second
+	other := makeBasePod(t, nodeName, "other", "100m", "500", "", []v1.ContainerPort{{HostIP: "127.0.0.1", HostPort: 80, Protocol: "TCP"}})
+	oldPod := makeBasePod(t, nodeName, "test", "100m", "500", "", nil)
+	newPod := oldPod.DeepCopy()
+	// newPod now collides with other's HostPort 80.
+	newPod.Spec.Containers[0].Ports = []v1.ContainerPort{{HostIP: "127.0.0.1", HostPort: 80, Protocol: "TCP"}}
+
+	cache := newSchedulerCache(testContext(), ttl, time.Second)
+	if err := cache.AddPod(testContext(), other); err != nil {
+		t.Fatalf("AddPod(other) failed: %v", err)
+	}
+	if err := cache.AddPod(testContext(), oldPod); err != nil {
+		t.Fatalf("AddPod(oldPod) failed: %v", err)
+	}
+
+	err := cache.UpdatePod(testContext(), oldPod, newPod)
+	if _, ok := err.(*CollisionError); !ok {
+		t.Fatalf("UpdatePod err = %v, want a *CollisionError", err)
+	}
+
+	// GetPod, List, and ByIndex should all observe newPod, not the stale oldPod.
+	got, getErr := cache.GetPod(newPod)
+	if getErr != nil {
+		t.Fatalf("GetPod failed: %v", getErr)
+	}
+	if !reflect.DeepEqual(got, newPod) {
+		t.Errorf("GetPod = %v, want %v", got, newPod)
+	}
+	pods, listErr := cache.ByIndex(ByNodeNameIndex, nodeName)
+	if listErr != nil {
+		t.Fatalf("ByIndex failed: %v", listErr)
+	}
+	if !hasPod(pods, newPod.Name) {
+		t.Errorf("ByIndex(byNodeName, %v) = %v, expecting %v among them", nodeName, podNames(pods), newPod.Name)
+	}
+
+	// The node's resource accounting should also reflect newPod's placement.
+	n := cache.nodeInfo(nodeName)
+	if len(n.pods) != 2 || !hasPod(n.pods, newPod.Name) {
+		t.Errorf("NodeInfo.Pods() = %v, expecting [other test] with test == newPod", podNames(n.pods))
+	}
+}
+
 // TestExpireAddUpdatePod test the sequence that a pod is expired, added, then updated
 func TestExpireAddUpdatePod(t *testing.T) {
 	nodeName := "node"
@@ -522,16 +613,16 @@ func TestExpireAddUpdatePod(t *testing.T) {
 
 	now := time.Now()
 	for _, tt := range tests {
-		cache := newSchedulerCache(ttl, time.Second, nil)
+		cache := newSchedulerCache(testContext(), ttl, time.Second)
 		for _, podToAssume := range tt.podsToAssume {
 			if err := assumeAndFinishBinding(cache, podToAssume, now); err != nil {
 				t.Fatalf("assumePod failed: %v", err)
 			}
 		}
-		cache.cleanupAssumedPods(now.Add(2 * ttl))
+		cache.cleanupAssumedPods(testContext(), now.Add(2 * ttl))
 
 		for _, podToAdd := range tt.podsToAdd {
-			if err := cache.AddPod(podToAdd); err != nil {
+			if err := cache.AddPod(testContext(), podToAdd); err != nil {
 				t.Fatalf("AddPod failed: %v", err)
 			}
 		}
@@ -540,11 +631,11 @@ func TestExpireAddUpdatePod(t *testing.T) {
 			if i == 0 {
 				continue
 			}
-			if err := cache.UpdatePod(tt.podsToUpdate[i-1], tt.podsToUpdate[i]); err != nil {
+			if err := cache.UpdatePod(testContext(), tt.podsToUpdate[i-1], tt.podsToUpdate[i]); err != nil {
 				t.Fatalf("UpdatePod failed: %v", err)
 			}
 			// check after expiration. confirmed pods shouldn't be expired.
-			n := cache.nodes[nodeName]
+			n := cache.nodeInfo(nodeName)
 			deepEqualWithoutGeneration(t, i, n, tt.wNodeInfo[i-1])
 		}
 	}
@@ -575,18 +666,18 @@ func TestRemovePod(t *testing.T) {
 	}}
 
 	for i, tt := range tests {
-		cache := newSchedulerCache(time.Second, time.Second, nil)
-		if err := cache.AddPod(tt.pod); err != nil {
+		cache := newSchedulerCache(testContext(), time.Second, time.Second)
+		if err := cache.AddPod(testContext(), tt.pod); err != nil {
 			t.Fatalf("AddPod failed: %v", err)
 		}
-		n := cache.nodes[nodeName]
+		n := cache.nodeInfo(nodeName)
 		deepEqualWithoutGeneration(t, i, n, tt.wNodeInfo)
 
-		if err := cache.RemovePod(tt.pod); err != nil {
+		if err := cache.RemovePod(testContext(), tt.pod); err != nil {
 			t.Fatalf("RemovePod failed: %v", err)
 		}
 
-		n = cache.nodes[nodeName]
+		n = cache.nodeInfo(nodeName)
 		if n != nil {
 			t.Errorf("#%d: expecting pod deleted and nil node info, get=%s", i, n)
 		}
@@ -605,7 +696,7 @@ func TestForgetPod(t *testing.T) {
 	ttl := 10 * time.Second
 
 	for i, tt := range tests {
-		cache := newSchedulerCache(ttl, time.Second, nil)
+		cache := newSchedulerCache(testContext(), ttl, time.Second)
 		for _, pod := range tt.pods {
 			if err := assumeAndFinishBinding(cache, pod, now); err != nil {
 				t.Fatalf("assumePod failed: %v", err)
@@ -629,7 +720,7 @@ func TestForgetPod(t *testing.T) {
 			}
 		}
 		for _, pod := range tt.pods {
-			if err := cache.ForgetPod(pod); err != nil {
+			if err := cache.ForgetPod(testContext(), pod); err != nil {
 				t.Fatalf("ForgetPod failed: %v", err)
 			}
 			isAssumed, err := cache.IsAssumedPod(pod)
@@ -640,13 +731,131 @@ func TestForgetPod(t *testing.T) {
 				t.Fatalf("Pod is expected to be unassumed.")
 			}
 		}
-		cache.cleanupAssumedPods(now.Add(2 * ttl))
-		if n := cache.nodes[nodeName]; n != nil {
+		cache.cleanupAssumedPods(testContext(), now.Add(2 * ttl))
+		if n := cache.nodeInfo(nodeName); n != nil {
 			t.Errorf("#%d: expecting pod deleted and nil node info, get=%s", i, n)
 		}
 	}
 }
 
+// TestExpirePodWithZeroTTLNeverExpires tests that an assumed pod cached with
+// ttl=0 survives arbitrarily long cleanup sweeps, since deadline is nil.
+func TestExpirePodWithZeroTTLNeverExpires(t *testing.T) {
+	nodeName := "node"
+	pod := makeBasePod(t, nodeName, "test-1", "100m", "500", "", []v1.ContainerPort{{HostIP: "127.0.0.1", HostPort: 80, Protocol: "TCP"}})
+	now := time.Now()
+
+	cache := newSchedulerCache(testContext(), 0, time.Second)
+	if err := assumeAndFinishBinding(cache, pod, now); err != nil {
+		t.Fatalf("assumePod failed: %v", err)
+	}
+
+	// Even a cleanup sweep far in the future must not expire the pod.
+	cache.cleanupAssumedPods(testContext(), now.Add(100 * 365 * 24 * time.Hour))
+
+	n := cache.nodeInfo(nodeName)
+	if n == nil {
+		t.Fatalf("expecting pod to survive cleanup, but node info was removed")
+	}
+	isAssumed, err := cache.IsAssumedPod(pod)
+	if err != nil {
+		t.Fatalf("IsAssumedPod failed: %v", err)
+	}
+	if !isAssumed {
+		t.Errorf("expecting pod to remain assumed with ttl=0")
+	}
+}
+
+// TestForgetPodWithZeroTTLStillWorks tests that ForgetPod still reclaims an
+// assumed pod explicitly even when ttl=0 disables wall-clock expiration.
+func TestForgetPodWithZeroTTLStillWorks(t *testing.T) {
+	nodeName := "node"
+	pod := makeBasePod(t, nodeName, "test-1", "100m", "500", "", []v1.ContainerPort{{HostIP: "127.0.0.1", HostPort: 80, Protocol: "TCP"}})
+	now := time.Now()
+
+	cache := newSchedulerCache(testContext(), 0, time.Second)
+	if err := assumeAndFinishBinding(cache, pod, now); err != nil {
+		t.Fatalf("assumePod failed: %v", err)
+	}
+	if err := cache.ForgetPod(testContext(), pod); err != nil {
+		t.Fatalf("ForgetPod failed: %v", err)
+	}
+	isAssumed, err := cache.IsAssumedPod(pod)
+	if err != nil {
+		t.Fatalf("IsAssumedPod failed: %v", err)
+	}
+	if isAssumed {
+		t.Errorf("expecting pod to be unassumed after ForgetPod")
+	}
+	if n := cache.nodeInfo(nodeName); n != nil {
+		t.Errorf("expecting pod deleted and nil node info, get=%s", n)
+	}
+}
+
+// TestReusedPodNameTracksIndependentLifecycles tests that two pods sharing a
+// namespace/name but with different UIDs - as happens when a pod is deleted
+// and a new one reusing the same name is created before the cache notices -
+// are tracked as independent lifecycles rather than colliding.
+func TestReusedPodNameTracksIndependentLifecycles(t *testing.T) {
+	nodeName := "node"
+	now := time.Now()
+	ttl := 10 * time.Second
+
+	oldPod := makeBasePod(t, nodeName, "test-1", "100m", "500", "", nil)
+	oldPod.UID = types.UID("old-uid")
+	newPod := makeBasePod(t, nodeName, "test-1", "100m", "500", "", nil)
+	newPod.UID = types.UID("new-uid")
+
+	cache := newSchedulerCache(testContext(), ttl, time.Second)
+	if err := assumeAndFinishBinding(cache, oldPod, now); err != nil {
+		t.Fatalf("assumePod(oldPod) failed: %v", err)
+	}
+
+	// A pod with the same namespace/name but a different UID must be
+	// assumable independently, not rejected as "already in the cache".
+	if err := cache.AssumePod(testContext(), newPod); err != nil {
+		t.Fatalf("AssumePod(newPod) failed: %v", err)
+	}
+
+	if assumed, err := cache.IsAssumedPod(oldPod); err != nil {
+		t.Fatalf("IsAssumedPod(oldPod) failed: %v", err)
+	} else if !assumed {
+		t.Errorf("expecting oldPod to remain independently assumed")
+	}
+	if assumed, err := cache.IsAssumedPod(newPod); err != nil {
+		t.Fatalf("IsAssumedPod(newPod) failed: %v", err)
+	} else if !assumed {
+		t.Errorf("expecting newPod to be assumed")
+	}
+
+	// GetPodByName resolves to whichever pod most recently claimed the
+	// namespace/name - newPod, since it was assumed last.
+	got, err := cache.GetPodByName(newPod.Namespace, newPod.Name)
+	if err != nil {
+		t.Fatalf("GetPodByName failed: %v", err)
+	}
+	if got.UID != newPod.UID {
+		t.Errorf("GetPodByName returned UID %v, want %v", got.UID, newPod.UID)
+	}
+
+	// Forgetting oldPod must not affect newPod's independent lifecycle.
+	if err := cache.ForgetPod(testContext(), oldPod); err != nil {
+		t.Fatalf("ForgetPod(oldPod) failed: %v", err)
+	}
+	if assumed, err := cache.IsAssumedPod(newPod); err != nil {
+		t.Fatalf("IsAssumedPod(newPod) failed: %v", err)
+	} else if !assumed {
+		t.Errorf("expecting newPod to remain assumed after oldPod was forgotten")
+	}
+	got, err = cache.GetPodByName(newPod.Namespace, newPod.Name)
+	if err != nil {
+		t.Fatalf("GetPodByName failed after ForgetPod(oldPod): %v", err)
+	}
+	if got.UID != newPod.UID {
+		t.Errorf("GetPodByName returned UID %v after ForgetPod(oldPod), want %v", got.UID, newPod.UID)
+	}
+}
+
 // getResourceRequest returns the resource request of all containers in Pods;
 // excuding initContainers.
 func getResourceRequest(pod *v1.Pod) v1.ResourceList {
@@ -680,6 +889,120 @@ func buildNodeInfo(node *v1.Node, pods []*v1.Pod) *NodeInfo {
 	return expected
 }
 
+// TestForeignPodAccounting tests that a foreign pod's resource usage is
+// reflected on its node but the pod itself is excluded from Pods(), and that
+// RemoveForeignPod reverses the accounting.
+func TestForeignPodAccounting(t *testing.T) {
+	nodeName := "node"
+	daemonSetPod := makeBasePod(t, nodeName, "ds-pod", "100m", "500", "", nil)
+
+	cache := newSchedulerCache(testContext(), time.Second, time.Second)
+	if err := cache.AddForeignPod(testContext(), daemonSetPod); err != nil {
+		t.Fatalf("AddForeignPod failed: %v", err)
+	}
+
+	n := cache.nodeInfo(nodeName)
+	if len(n.Pods()) != 0 {
+		t.Errorf("expecting no pods in Pods(), got %v", n.Pods())
+	}
+	if got := n.RequestedResource(); got.MilliCPU != 100 || got.Memory != 500 {
+		t.Errorf("expecting requestedResource {100, 500}, got %#v", got)
+	}
+	foreign := cache.ForeignAllocations(nodeName)
+	if len(foreign) != 1 || foreign[0] != daemonSetPod.UID {
+		t.Errorf("expecting ForeignAllocations=[%v], got %v", daemonSetPod.UID, foreign)
+	}
+
+	// Calling AddForeignPod again with the same pod must not double-count it.
+	if err := cache.AddForeignPod(testContext(), daemonSetPod); err != nil {
+		t.Fatalf("AddForeignPod (repeat) failed: %v", err)
+	}
+	if got := n.RequestedResource(); got.MilliCPU != 100 || got.Memory != 500 {
+		t.Errorf("expecting requestedResource to stay {100, 500} after repeat AddForeignPod, got %#v", got)
+	}
+
+	if err := cache.RemoveForeignPod(testContext(), daemonSetPod); err != nil {
+		t.Fatalf("RemoveForeignPod failed: %v", err)
+	}
+	if n := cache.nodeInfo(nodeName); n != nil {
+		t.Errorf("expecting node info removed once its only foreign pod is gone, got=%s", n)
+	}
+}
+
+// TestForeignPodHostPortConflict tests that a foreign pod's HostPort claim is
+// tracked in usedPorts, so a later scheduler-bound pod reusing the same
+// HostPort is reported as a conflict instead of silently double-binding it -
+// and that removing both pods leaves nothing behind on the node.
+func TestForeignPodHostPortConflict(t *testing.T) {
+	nodeName := "node"
+	portKey := "TCP/127.0.0.1/80"
+	ingressDaemon := makeBasePod(t, nodeName, "ingress-ds", "100m", "500", "", []v1.ContainerPort{{HostIP: "127.0.0.1", HostPort: 80, Protocol: "TCP"}})
+	colliding := makeBasePod(t, nodeName, "colliding", "100m", "500", "", []v1.ContainerPort{{HostIP: "127.0.0.1", HostPort: 80, Protocol: "TCP"}})
+
+	cache := newSchedulerCache(testContext(), time.Second, time.Second)
+	if err := cache.AddForeignPod(testContext(), ingressDaemon); err != nil {
+		t.Fatalf("AddForeignPod failed: %v", err)
+	}
+	if n := cache.nodeInfo(nodeName); !n.UsedPorts()[portKey] {
+		t.Fatalf("expecting HostPort 80 claimed by the foreign pod, usedPorts=%v", n.UsedPorts())
+	}
+
+	if err := cache.AddPod(testContext(), colliding); err == nil {
+		t.Fatalf("expecting AddPod to report a HostPort collision against the foreign pod, got no error")
+	} else if _, ok := err.(*CollisionError); !ok {
+		t.Fatalf("expecting a *CollisionError, got %T: %v", err, err)
+	}
+	// addPod still applies the placement despite the collision (same
+	// contract as any other conflicting AddPod); undo it so only the
+	// foreign pod remains for the next assertion. usedPorts is a plain set,
+	// so this also clears the port key the two pods shared.
+	if err := cache.RemovePod(testContext(), colliding); err != nil {
+		t.Fatalf("RemovePod(colliding) failed: %v", err)
+	}
+
+	if err := cache.RemoveForeignPod(testContext(), ingressDaemon); err != nil {
+		t.Fatalf("RemoveForeignPod failed: %v", err)
+	}
+	if n := cache.nodeInfo(nodeName); n != nil {
+		t.Errorf("expecting node info removed once its only (foreign) pod is gone, got=%s", n)
+	}
+}
+
+// TestForeignPodPromotedWhenScheduled tests that a pod the scheduler binds
+// after previously seeing it as foreign (e.g. a DaemonSet pod it now manages
+// directly) is promoted into Pods() without its resource usage being
+// double-counted.
+func TestForeignPodPromotedWhenScheduled(t *testing.T) {
+	nodeName := "node"
+	pod := makeBasePod(t, nodeName, "ds-pod", "100m", "500", "", nil)
+
+	cache := newSchedulerCache(testContext(), time.Second, time.Second)
+	if err := cache.AddForeignPod(testContext(), pod); err != nil {
+		t.Fatalf("AddForeignPod failed: %v", err)
+	}
+	if err := cache.AddPod(testContext(), pod); err != nil {
+		t.Fatalf("AddPod failed: %v", err)
+	}
+
+	n := cache.nodeInfo(nodeName)
+	if len(n.Pods()) != 1 || n.Pods()[0].UID != pod.UID {
+		t.Errorf("expecting pod promoted into Pods(), got %v", n.Pods())
+	}
+	if len(cache.ForeignAllocations(nodeName)) != 0 {
+		t.Errorf("expecting no foreign allocations left after promotion, got %v", cache.ForeignAllocations(nodeName))
+	}
+	if got := n.RequestedResource(); got.MilliCPU != 100 || got.Memory != 500 {
+		t.Errorf("expecting requestedResource to stay {100, 500} after promotion, got %#v", got)
+	}
+
+	if err := cache.RemovePod(testContext(), pod); err != nil {
+		t.Fatalf("RemovePod failed: %v", err)
+	}
+	if n := cache.nodeInfo(nodeName); n != nil {
+		t.Errorf("expecting node info removed once its only pod is gone, got=%s", n)
+	}
+}
+
 // TestNodeOperators tests node operations of cache, including add, update
 // and remove.
 func TestNodeOperators(t *testing.T) {
@@ -816,15 +1139,15 @@ func TestNodeOperators(t *testing.T) {
 		expected := buildNodeInfo(test.node, test.pods)
 		node := test.node
 
-		cache := newSchedulerCache(time.Second, time.Second, nil)
-		cache.AddNode(node)
+		cache := newSchedulerCache(testContext(), time.Second, time.Second)
+		cache.AddNode(testContext(), node)
 		for _, pod := range test.pods {
-			cache.AddPod(pod)
+			cache.AddPod(testContext(), pod)
 		}
 
 		// Case 1: the node was added into cache successfully.
-		got, found := cache.nodes[node.Name]
-		if !found {
+		got := cache.nodeInfo(node.Name)
+		if got == nil {
 			t.Errorf("Failed to find node %v in schedulercache.", node.Name)
 		}
 
@@ -847,9 +1170,9 @@ func TestNodeOperators(t *testing.T) {
 		node.Status.Allocatable[v1.ResourceMemory] = mem_50m
 		expected.allocatableResource.Memory = mem_50m.Value()
 		expected.generation++
-		cache.UpdateNode(nil, node)
-		got, found = cache.nodes[node.Name]
-		if !found {
+		cache.UpdateNode(testContext(), nil, node)
+		got = cache.nodeInfo(node.Name)
+		if got == nil {
 			t.Errorf("Failed to find node %v in schedulercache after UpdateNode.", node.Name)
 		}
 
@@ -858,8 +1181,8 @@ func TestNodeOperators(t *testing.T) {
 		}
 
 		// Case 4: the node can not be removed if pods is not empty.
-		cache.RemoveNode(node)
-		if _, found := cache.nodes[node.Name]; !found {
+		cache.RemoveNode(testContext(), node)
+		if cache.nodeInfo(node.Name) == nil {
 			t.Errorf("The node %v should not be removed if pods is not empty.", node.Name)
 		}
 	}
@@ -873,6 +1196,47 @@ func BenchmarkList1kNodes30kPods(b *testing.B) {
 	}
 }
 
+// BenchmarkUpdateNodeNameToInfoMap1kNodesMutate10 proves that
+// UpdateNodeNameToInfoMap's cost tracks the number of nodes mutated since the
+// last call, not the size of the cluster: with 1000 nodes cached but only 10
+// touched between calls, each call should walk roughly 10 list entries
+// rather than all 1000.
+func BenchmarkUpdateNodeNameToInfoMap1kNodesMutate10(b *testing.B) {
+	cache := newSchedulerCache(testContext(), time.Second, time.Second)
+	for i := 0; i < 1000; i++ {
+		nodeName := fmt.Sprintf("node-%d", i)
+		for j := 0; j < 30; j++ {
+			objName := fmt.Sprintf("%s-pod-%d", nodeName, j)
+			pod := makeBasePod(b, nodeName, objName, "0", "0", "", nil)
+			if err := cache.AddPod(testContext(), pod); err != nil {
+				b.Fatalf("AddPod failed: %v", err)
+			}
+		}
+	}
+
+	infoMap := map[string]*NodeInfo{}
+	if err := cache.UpdateNodeNameToInfoMap(infoMap); err != nil {
+		b.Fatalf("UpdateNodeNameToInfoMap failed: %v", err)
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		b.StopTimer()
+		for i := 0; i < 10; i++ {
+			nodeName := fmt.Sprintf("node-%d", i)
+			objName := fmt.Sprintf("%s-extra-pod-%d", nodeName, n)
+			pod := makeBasePod(b, nodeName, objName, "0", "0", "", nil)
+			if err := cache.AddPod(testContext(), pod); err != nil {
+				b.Fatalf("AddPod failed: %v", err)
+			}
+		}
+		b.StartTimer()
+		if err := cache.UpdateNodeNameToInfoMap(infoMap); err != nil {
+			b.Fatalf("UpdateNodeNameToInfoMap failed: %v", err)
+		}
+	}
+}
+
 func BenchmarkExpire100Pods(b *testing.B) {
 	benchmarkExpire(b, 100)
 }
@@ -891,7 +1255,7 @@ func benchmarkExpire(b *testing.B, podNum int) {
 		b.StopTimer()
 		cache := setupCacheWithAssumedPods(b, podNum, now)
 		b.StartTimer()
-		cache.cleanupAssumedPods(now.Add(2 * time.Second))
+		cache.cleanupAssumedPods(testContext(), now.Add(2 * time.Second))
 	}
 }
 
@@ -933,14 +1297,14 @@ func makeBasePod(t testingMode, nodeName, objName, cpu, mem, extended string, po
 }
 
 func setupCacheOf1kNodes30kPods(b *testing.B) Cache {
-	cache := newSchedulerCache(time.Second, time.Second, nil)
+	cache := newSchedulerCache(testContext(), time.Second, time.Second)
 	for i := 0; i < 1000; i++ {
 		nodeName := fmt.Sprintf("node-%d", i)
 		for j := 0; j < 30; j++ {
 			objName := fmt.Sprintf("%s-pod-%d", nodeName, j)
 			pod := makeBasePod(b, nodeName, objName, "0", "0", "", nil)
 
-			if err := cache.AddPod(pod); err != nil {
+			if err := cache.AddPod(testContext(), pod); err != nil {
 				b.Fatalf("AddPod failed: %v", err)
 			}
 		}
@@ -949,7 +1313,7 @@ func setupCacheOf1kNodes30kPods(b *testing.B) Cache {
 }
 
 func setupCacheWithAssumedPods(b *testing.B, podNum int, assumedTime time.Time) *schedulerCache {
-	cache := newSchedulerCache(time.Second, time.Second, nil)
+	cache := newSchedulerCache(testContext(), time.Second, time.Second)
 	for i := 0; i < podNum; i++ {
 		nodeName := fmt.Sprintf("node-%d", i/10)
 		objName := fmt.Sprintf("%s-pod-%d", nodeName, i%10)
@@ -980,6 +1344,76 @@ func makePDB(name, namespace string, labels map[string]string, minAvailable int)
 	return pdb
 }
 
+func makeNodePDB(minAvailable int) *v1beta1.PodDisruptionBudget {
+	intstrMin := intstr.FromInt(minAvailable)
+	return &v1beta1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-pdb"},
+		Spec:       v1beta1.PodDisruptionBudgetSpec{MinAvailable: &intstrMin},
+	}
+}
+
+// TestNodePDBOperations mirrors TestPDBOperations for node-scoped PDBs: add,
+// update, and remove a node PDB and confirm AllowedDisruptions reflects the
+// node's current pod count at each step.
+func TestNodePDBOperations(t *testing.T) {
+	cache := newSchedulerCache(testContext(), time.Second, time.Second)
+
+	pod1 := makeBasePod(t, "node-1", "pod1", "100m", "500", "", nil)
+	pod2 := makeBasePod(t, "node-1", "pod2", "100m", "500", "", nil)
+	for _, pod := range []*v1.Pod{pod1, pod2} {
+		if err := cache.AddPod(testContext(), pod); err != nil {
+			t.Fatalf("AddPod(%v) failed: %v", pod.Name, err)
+		}
+	}
+
+	pdb := makeNodePDB(1)
+	if err := cache.AddNodePDB(testContext(), "node-1", pdb); err != nil {
+		t.Fatalf("AddNodePDB failed: %v", err)
+	}
+	if got := cache.NodePDBsForNode("node-1"); !reflect.DeepEqual(got, pdb) {
+		t.Errorf("NodePDBsForNode(node-1) = %v, want %v", got, pdb)
+	}
+	// 2 pods, MinAvailable 1: one of them may be evicted.
+	if got := cache.AllowedDisruptions("node-1"); got != 1 {
+		t.Errorf("AllowedDisruptions(node-1) = %v, want 1", got)
+	}
+
+	updated := makeNodePDB(2)
+	if err := cache.UpdateNodePDB(testContext(), "node-1", updated); err != nil {
+		t.Fatalf("UpdateNodePDB failed: %v", err)
+	}
+	// 2 pods, MinAvailable 2: none may be evicted.
+	if got := cache.AllowedDisruptions("node-1"); got != 0 {
+		t.Errorf("AllowedDisruptions(node-1) after update = %v, want 0", got)
+	}
+
+	// A pod moving to a different node via UpdatePod shrinks node-1's pod
+	// count and grows node-2's, even though node-2 has no node PDB of its own.
+	movedPod := pod2.DeepCopy()
+	movedPod.Spec.NodeName = "node-2"
+	if err := cache.UpdatePod(testContext(), pod2, movedPod); err != nil {
+		t.Fatalf("UpdatePod failed: %v", err)
+	}
+	// node-1: 1 pod, MinAvailable 2 -> no headroom, clamped at 0.
+	if got := cache.AllowedDisruptions("node-1"); got != 0 {
+		t.Errorf("AllowedDisruptions(node-1) after pod moved away = %v, want 0", got)
+	}
+	// node-2: 1 pod, no node PDB registered -> unconstrained.
+	if got := cache.AllowedDisruptions("node-2"); got != -1 {
+		t.Errorf("AllowedDisruptions(node-2) = %v, want -1 (no node PDB)", got)
+	}
+
+	if err := cache.RemoveNodePDB(testContext(), "node-1"); err != nil {
+		t.Fatalf("RemoveNodePDB failed: %v", err)
+	}
+	if got := cache.NodePDBsForNode("node-1"); got != nil {
+		t.Errorf("NodePDBsForNode(node-1) after removal = %v, want nil", got)
+	}
+	if got := cache.AllowedDisruptions("node-1"); got != -1 {
+		t.Errorf("AllowedDisruptions(node-1) after removal = %v, want -1 (no node PDB)", got)
+	}
+}
+
 // TestPDBOperations tests that a PDB will be add/updated/deleted correctly.
 func TestPDBOperations(t *testing.T) {
 	ttl := 10 * time.Second
@@ -1018,9 +1452,9 @@ func TestPDBOperations(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		cache := newSchedulerCache(ttl, time.Second, nil)
+		cache := newSchedulerCache(testContext(), ttl, time.Second)
 		for _, pdbToAdd := range test.pdbsToAdd {
-			if err := cache.AddPDB(pdbToAdd); err != nil {
+			if err := cache.AddPDB(testContext(), pdbToAdd); err != nil {
 				t.Fatalf("AddPDB failed: %v", err)
 			}
 		}
@@ -1029,13 +1463,13 @@ func TestPDBOperations(t *testing.T) {
 			if i == 0 {
 				continue
 			}
-			if err := cache.UpdatePDB(test.pdbsToUpdate[i-1], test.pdbsToUpdate[i]); err != nil {
+			if err := cache.UpdatePDB(testContext(), test.pdbsToUpdate[i-1], test.pdbsToUpdate[i]); err != nil {
 				t.Fatalf("UpdatePDB failed: %v", err)
 			}
 		}
 
 		for _, pdb := range test.pdbsToDelete {
-			if err := cache.RemovePDB(pdb); err != nil {
+			if err := cache.RemovePDB(testContext(), pdb); err != nil {
 				t.Fatalf("RemovePDB failed: %v", err)
 			}
 		}
@@ -0,0 +1,113 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedulercache
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/api/core/v1"
+)
+
+// ConflictReason identifies the resource dimension on which a pod placement
+// collided with a node's existing allocation.
+type ConflictReason string
+
+const (
+	// HostPortConflict means the pod claims a HostIP/Protocol/HostPort tuple
+	// that's already in use by another pod on the node.
+	HostPortConflict ConflictReason = "HostPort"
+	// CPUConflict means placing the pod would push the node's requested CPU
+	// past its allocatable CPU.
+	CPUConflict ConflictReason = "CPU"
+	// MemoryConflict means placing the pod would push the node's requested
+	// memory past its allocatable memory.
+	MemoryConflict ConflictReason = "Memory"
+	// ScalarResourceConflict means placing the pod would push the node's
+	// requested amount of an extended resource past what's allocatable.
+	ScalarResourceConflict ConflictReason = "ScalarResource"
+)
+
+// Conflict describes a single dimension on which adding NewPod to a node
+// would collide with the node's existing allocation.
+type Conflict struct {
+	Reason ConflictReason
+	NewPod *v1.Pod
+
+	// ConflictingPod is set only for HostPortConflict: the other pod already
+	// holding the contended port. It is nil when no pod in the node's Pods()
+	// holds the port, e.g. when the holder is a foreign allocation.
+	ConflictingPod *v1.Pod
+
+	// ResourceName is set only for ScalarResourceConflict.
+	ResourceName v1.ResourceName
+
+	// Requested and Allocatable are set for CPU/Memory/ScalarResource
+	// conflicts: Requested is what the node's total would become if NewPod
+	// were admitted, Allocatable is the node's capacity for that dimension.
+	Requested   int64
+	Allocatable int64
+}
+
+func (c Conflict) String() string {
+	switch c.Reason {
+	case HostPortConflict:
+		if c.ConflictingPod == nil {
+			return fmt.Sprintf("pod %s/%s wants a host port already claimed on the node",
+				c.NewPod.Namespace, c.NewPod.Name)
+		}
+		return fmt.Sprintf("pod %s/%s wants a host port already used by pod %s/%s",
+			c.NewPod.Namespace, c.NewPod.Name, c.ConflictingPod.Namespace, c.ConflictingPod.Name)
+	case ScalarResourceConflict:
+		return fmt.Sprintf("pod %s/%s would push %s usage to %d, over allocatable %d",
+			c.NewPod.Namespace, c.NewPod.Name, c.ResourceName, c.Requested, c.Allocatable)
+	default:
+		return fmt.Sprintf("pod %s/%s would push %s usage to %d, over allocatable %d",
+			c.NewPod.Namespace, c.NewPod.Name, c.Reason, c.Requested, c.Allocatable)
+	}
+}
+
+// CollisionError is returned when placing a pod on a node collides with the
+// node's existing allocation on one or more dimensions. The cache still
+// applies the placement so callers can observe and reconcile the collision
+// rather than lose the event.
+type CollisionError struct {
+	Node      string
+	Conflicts []Conflict
+}
+
+func (e *CollisionError) Error() string {
+	reasons := make([]string, 0, len(e.Conflicts))
+	for _, c := range e.Conflicts {
+		reasons = append(reasons, c.String())
+	}
+	return fmt.Sprintf("collision placing pod on node %s: %s", e.Node, strings.Join(reasons, "; "))
+}
+
+var collisionsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Subsystem: "scheduler_cache",
+		Name:      "collisions_total",
+		Help:      "Number of pod placements that collided with a node's existing allocation, by reason.",
+	},
+	[]string{"reason"},
+)
+
+func init() {
+	prometheus.MustRegister(collisionsTotal)
+}
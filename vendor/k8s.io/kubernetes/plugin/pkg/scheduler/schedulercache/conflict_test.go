@@ -0,0 +1,153 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedulercache
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func makeNodeWithAllocatable(name, cpu, mem, scalarName, scalar string) *v1.Node {
+	allocatable := v1.ResourceList{
+		v1.ResourceCPU:    resource.MustParse(cpu),
+		v1.ResourceMemory: resource.MustParse(mem),
+	}
+	if scalarName != "" {
+		allocatable[v1.ResourceName(scalarName)] = resource.MustParse(scalar)
+	}
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status:     v1.NodeStatus{Allocatable: allocatable},
+	}
+}
+
+func collisionReasons(t *testing.T, err error) []ConflictReason {
+	t.Helper()
+	collisionErr, ok := err.(*CollisionError)
+	if !ok {
+		t.Fatalf("expected a *CollisionError, got %T: %v", err, err)
+	}
+	reasons := make([]ConflictReason, len(collisionErr.Conflicts))
+	for i, c := range collisionErr.Conflicts {
+		reasons[i] = c.Reason
+	}
+	return reasons
+}
+
+func hasReason(reasons []ConflictReason, want ConflictReason) bool {
+	for _, r := range reasons {
+		if r == want {
+			return true
+		}
+	}
+	return false
+}
+
+// TestAddPodHostPortCollision tests that reusing a HostIP/Protocol/HostPort
+// tuple already claimed on the node is reported as a collision, while the
+// second pod's placement is still applied.
+func TestAddPodHostPortCollision(t *testing.T) {
+	nodeName := "node"
+	first := makeBasePod(t, nodeName, "first", "100m", "500", "", []v1.ContainerPort{{HostIP: "127.0.0.1", HostPort: 80, Protocol: "TCP"}})
+	second := makeBasePod(t, nodeName, "second", "100m", "500", "", []v1.ContainerPort{{HostIP: "127.0.0.1", HostPort: 80, Protocol: "TCP"}})
+
+	cache := newSchedulerCache(testContext(), time.Second, time.Second)
+	if err := cache.AddPod(testContext(), first); err != nil {
+		t.Fatalf("AddPod(first) failed: %v", err)
+	}
+	err := cache.AddPod(testContext(), second)
+	if err == nil {
+		t.Fatalf("expected a collision error adding a pod that reuses a claimed host port")
+	}
+	if !hasReason(collisionReasons(t, err), HostPortConflict) {
+		t.Errorf("expected HostPortConflict, got %v", err)
+	}
+
+	n := cache.nodeInfo(nodeName)
+	if len(n.pods) != 2 {
+		t.Errorf("expected the colliding pod to still be applied, got %d pods", len(n.pods))
+	}
+}
+
+// TestAddPodCPUOvercommitCollision tests that scheduling a pod past a node's
+// allocatable CPU is reported as a collision, while still being applied.
+func TestAddPodCPUOvercommitCollision(t *testing.T) {
+	nodeName := "node"
+	node := makeNodeWithAllocatable(nodeName, "1", "1Gi", "", "")
+	pod := makeBasePod(t, nodeName, "test", "2000m", "500", "", nil)
+
+	cache := newSchedulerCache(testContext(), time.Second, time.Second)
+	if err := cache.AddNode(testContext(), node); err != nil {
+		t.Fatalf("AddNode failed: %v", err)
+	}
+	err := cache.AddPod(testContext(), pod)
+	if err == nil {
+		t.Fatalf("expected a collision error over-committing CPU")
+	}
+	if !hasReason(collisionReasons(t, err), CPUConflict) {
+		t.Errorf("expected CPUConflict, got %v", err)
+	}
+	if n := cache.nodeInfo(nodeName); len(n.pods) != 1 {
+		t.Errorf("expected the colliding pod to still be applied, got %d pods", len(n.pods))
+	}
+}
+
+// TestAddPodMemoryOvercommitCollision tests that scheduling a pod past a
+// node's allocatable memory is reported as a collision, while still being
+// applied.
+func TestAddPodMemoryOvercommitCollision(t *testing.T) {
+	nodeName := "node"
+	node := makeNodeWithAllocatable(nodeName, "4", "1Ki", "", "")
+	pod := makeBasePod(t, nodeName, "test", "100m", "2Ki", "", nil)
+
+	cache := newSchedulerCache(testContext(), time.Second, time.Second)
+	if err := cache.AddNode(testContext(), node); err != nil {
+		t.Fatalf("AddNode failed: %v", err)
+	}
+	err := cache.AddPod(testContext(), pod)
+	if err == nil {
+		t.Fatalf("expected a collision error over-committing memory")
+	}
+	if !hasReason(collisionReasons(t, err), MemoryConflict) {
+		t.Errorf("expected MemoryConflict, got %v", err)
+	}
+}
+
+// TestAddPodScalarResourceOvercommitCollision tests that scheduling a pod
+// past a node's allocatable extended resource is reported as a collision,
+// while still being applied.
+func TestAddPodScalarResourceOvercommitCollision(t *testing.T) {
+	nodeName := "node"
+	node := makeNodeWithAllocatable(nodeName, "4", "1Gi", "example.com/foo", "2")
+	pod := makeBasePod(t, nodeName, "test", "100m", "500", "example.com/foo:3", nil)
+
+	cache := newSchedulerCache(testContext(), time.Second, time.Second)
+	if err := cache.AddNode(testContext(), node); err != nil {
+		t.Fatalf("AddNode failed: %v", err)
+	}
+	err := cache.AddPod(testContext(), pod)
+	if err == nil {
+		t.Fatalf("expected a collision error over-committing a scalar resource")
+	}
+	if !hasReason(collisionReasons(t, err), ScalarResourceConflict) {
+		t.Errorf("expected ScalarResourceConflict, got %v", err)
+	}
+}
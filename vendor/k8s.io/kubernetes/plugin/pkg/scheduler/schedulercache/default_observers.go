@@ -0,0 +1,125 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedulercache
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/api/core/v1"
+)
+
+// PrometheusObserver is a CacheObserver that exports the cache's assumed-pod
+// count and per-node requested resource as Prometheus gauges.
+//
+// The gauges themselves are package-level and registered exactly once (see
+// init below), the same pattern conflict.go uses for collisionsTotal:
+// multiple schedulerCache instances in one process - a second cache, a test
+// in another package, a restart path that recreates the cache - all share
+// the same registered collectors instead of each construction attempting its
+// own registration and panicking on the second one.
+type PrometheusObserver struct {
+	assumedPods         prometheus.Gauge
+	nodeRequestedCPU    *prometheus.GaugeVec
+	nodeRequestedMemory *prometheus.GaugeVec
+}
+
+var (
+	assumedPodsGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Subsystem: "scheduler_cache",
+		Name:      "assumed_pods",
+		Help:      "Number of pods currently assumed by the scheduler cache.",
+	})
+	nodeRequestedCPUGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Subsystem: "scheduler_cache",
+		Name:      "node_requested_cpu_millicores",
+		Help:      "Aggregated CPU, in millicores, requested by pods on a node.",
+	}, []string{"node"})
+	nodeRequestedMemoryGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Subsystem: "scheduler_cache",
+		Name:      "node_requested_memory_bytes",
+		Help:      "Aggregated memory, in bytes, requested by pods on a node.",
+	}, []string{"node"})
+)
+
+func init() {
+	prometheus.MustRegister(assumedPodsGauge, nodeRequestedCPUGauge, nodeRequestedMemoryGauge)
+}
+
+// NewPrometheusObserver creates a PrometheusObserver backed by this
+// package's already-registered gauges.
+func NewPrometheusObserver() *PrometheusObserver {
+	return &PrometheusObserver{
+		assumedPods:         assumedPodsGauge,
+		nodeRequestedCPU:    nodeRequestedCPUGauge,
+		nodeRequestedMemory: nodeRequestedMemoryGauge,
+	}
+}
+
+func (o *PrometheusObserver) record(event CacheEvent) {
+	o.assumedPods.Set(float64(event.AssumedPods))
+	if event.Node == "" {
+		return
+	}
+	o.nodeRequestedCPU.WithLabelValues(event.Node).Set(float64(event.RequestedResource.MilliCPU))
+	o.nodeRequestedMemory.WithLabelValues(event.Node).Set(float64(event.RequestedResource.Memory))
+}
+
+func (o *PrometheusObserver) OnAssume(event CacheEvent)                { o.record(event) }
+func (o *PrometheusObserver) OnFinishBinding(event CacheEvent)         { o.record(event) }
+func (o *PrometheusObserver) OnAdd(event CacheEvent)                   { o.record(event) }
+func (o *PrometheusObserver) OnUpdate(_ *v1.Pod, event CacheEvent)     { o.record(event) }
+func (o *PrometheusObserver) OnRemove(event CacheEvent)                { o.record(event) }
+func (o *PrometheusObserver) OnForget(event CacheEvent)                { o.record(event) }
+func (o *PrometheusObserver) OnExpire(event CacheEvent, reason string) { o.record(event) }
+
+// LoggingObserver is a CacheObserver that logs each lifecycle event via a
+// logr.Logger pulled from a caller-provided context, so tests and production
+// code can attach contextual logging without modifying the cache internals.
+type LoggingObserver struct {
+	log logr.Logger
+}
+
+// NewLoggingObserver returns a LoggingObserver that logs with the
+// logr.Logger found in ctx, or discards its output if ctx carries none.
+func NewLoggingObserver(ctx context.Context) *LoggingObserver {
+	return &LoggingObserver{log: logr.FromContextOrDiscard(ctx)}
+}
+
+func (o *LoggingObserver) logEvent(verb string, event CacheEvent) {
+	o.log.Info(verb, "pod", klogObjString(event.Pod), "node", event.Node, "assumedPods", event.AssumedPods)
+}
+
+func (o *LoggingObserver) OnAssume(event CacheEvent)        { o.logEvent("assumed", event) }
+func (o *LoggingObserver) OnFinishBinding(event CacheEvent) { o.logEvent("finishedBinding", event) }
+func (o *LoggingObserver) OnAdd(event CacheEvent)           { o.logEvent("added", event) }
+func (o *LoggingObserver) OnUpdate(_ *v1.Pod, event CacheEvent) {
+	o.logEvent("updated", event)
+}
+func (o *LoggingObserver) OnRemove(event CacheEvent) { o.logEvent("removed", event) }
+func (o *LoggingObserver) OnForget(event CacheEvent) { o.logEvent("forgotten", event) }
+func (o *LoggingObserver) OnExpire(event CacheEvent, reason string) {
+	o.log.Info("expired", "pod", klogObjString(event.Pod), "node", event.Node, "reason", reason)
+}
+
+func klogObjString(pod *v1.Pod) string {
+	if pod == nil {
+		return ""
+	}
+	return pod.Namespace + "/" + pod.Name
+}
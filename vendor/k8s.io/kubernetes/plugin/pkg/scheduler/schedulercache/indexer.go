@@ -0,0 +1,169 @@
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedulercache
+
+import (
+	"fmt"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// Pod index names. ByIndex looks pods up by one of these.
+const (
+	ByNamespaceIndex = "byNamespace"
+	ByNodeNameIndex  = "byNodeName"
+	ByOwnerUIDIndex  = "byOwnerUID"
+)
+
+// podIndexFunc computes the set of index values pod should be filed under
+// for a single index.
+type podIndexFunc func(pod *v1.Pod) []string
+
+// podIndexers are the registered pod indices, keyed by index name.
+var podIndexers = map[string]podIndexFunc{
+	ByNamespaceIndex: func(pod *v1.Pod) []string {
+		return []string{pod.Namespace}
+	},
+	ByNodeNameIndex: func(pod *v1.Pod) []string {
+		if pod.Spec.NodeName == "" {
+			return nil
+		}
+		return []string{pod.Spec.NodeName}
+	},
+	ByOwnerUIDIndex: func(pod *v1.Pod) []string {
+		if len(pod.OwnerReferences) == 0 {
+			return nil
+		}
+		values := make([]string, 0, len(pod.OwnerReferences))
+		for _, ref := range pod.OwnerReferences {
+			values = append(values, string(ref.UID))
+		}
+		return values
+	},
+}
+
+// podIndex is a reverse index from an index name and indexed value to the
+// set of pod keys (UIDs) currently filed under it, modeled on client-go's
+// ThreadSafeStore indexing. It's kept in sync transactionally by
+// schedulerCache.indexPod/unindexPod alongside podStates, rather than
+// recomputed by scanning every pod on each lookup.
+type podIndex struct {
+	indexers map[string]podIndexFunc
+	// values[indexName][indexedValue] is the set of pod keys filed there.
+	values map[string]map[string]sets.String
+}
+
+func newPodIndex(indexers map[string]podIndexFunc) *podIndex {
+	values := make(map[string]map[string]sets.String, len(indexers))
+	for name := range indexers {
+		values[name] = map[string]sets.String{}
+	}
+	return &podIndex{indexers: indexers, values: values}
+}
+
+// add files key under every value each registered indexer computes for pod.
+// Callers must hold schedulerCache.mu.
+func (idx *podIndex) add(key string, pod *v1.Pod) {
+	for name, indexFunc := range idx.indexers {
+		for _, value := range indexFunc(pod) {
+			set, ok := idx.values[name][value]
+			if !ok {
+				set = sets.String{}
+				idx.values[name][value] = set
+			}
+			set.Insert(key)
+		}
+	}
+}
+
+// remove reverses a prior add for pod under the same key. Callers must hold
+// schedulerCache.mu.
+func (idx *podIndex) remove(key string, pod *v1.Pod) {
+	for name, indexFunc := range idx.indexers {
+		for _, value := range indexFunc(pod) {
+			set, ok := idx.values[name][value]
+			if !ok {
+				continue
+			}
+			set.Delete(key)
+			if set.Len() == 0 {
+				delete(idx.values[name], value)
+			}
+		}
+	}
+}
+
+// keys returns the pod keys filed under indexName/value. Callers must hold
+// schedulerCache.mu.
+func (idx *podIndex) keys(indexName, value string) (sets.String, error) {
+	byValue, ok := idx.values[indexName]
+	if !ok {
+		return nil, fmt.Errorf("index %q is not registered", indexName)
+	}
+	return byValue[value], nil
+}
+
+// pdbByNamespaceIndex is the only index PDBsForPod needs: a PodDisruptionBudget's
+// Spec.Selector is an arbitrary label selector, which (unlike a single
+// indexed value) can't be reversed into a map key in general. Namespacing the
+// PDBs first - selectors never match across namespaces - still turns a scan
+// of every PDB in the cluster into a scan of every PDB in the pod's own
+// namespace.
+const pdbByNamespaceIndex = "byPDBSelector"
+
+// pdbIndex is podIndex's counterpart for PodDisruptionBudgets, indexed by
+// namespace under pdbByNamespaceIndex.
+type pdbIndex struct {
+	// keysByNamespace[namespace] is the set of PDB keys (namespace/name) in
+	// that namespace.
+	keysByNamespace map[string]sets.String
+}
+
+func newPDBIndex() *pdbIndex {
+	return &pdbIndex{keysByNamespace: map[string]sets.String{}}
+}
+
+// add files key under pdb's namespace. Callers must hold schedulerCache.mu.
+func (idx *pdbIndex) add(key string, pdb *v1beta1.PodDisruptionBudget) {
+	set, ok := idx.keysByNamespace[pdb.Namespace]
+	if !ok {
+		set = sets.String{}
+		idx.keysByNamespace[pdb.Namespace] = set
+	}
+	set.Insert(key)
+}
+
+// remove reverses a prior add for pdb under the same key. Callers must hold
+// schedulerCache.mu.
+func (idx *pdbIndex) remove(key string, pdb *v1beta1.PodDisruptionBudget) {
+	set, ok := idx.keysByNamespace[pdb.Namespace]
+	if !ok {
+		return
+	}
+	set.Delete(key)
+	if set.Len() == 0 {
+		delete(idx.keysByNamespace, pdb.Namespace)
+	}
+}
+
+// keys returns the PDB keys filed under namespace. Callers must hold
+// schedulerCache.mu.
+func (idx *pdbIndex) keys(namespace string) sets.String {
+	return idx.keysByNamespace[namespace]
+}
@@ -0,0 +1,199 @@
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedulercache
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func podNames(pods []*v1.Pod) []string {
+	names := make([]string, 0, len(pods))
+	for _, p := range pods {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+func hasPod(pods []*v1.Pod, name string) bool {
+	for _, n := range podNames(pods) {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// TestByIndexNamespaceAndNode tests that ByIndex finds pods filed under the
+// namespace and node-name indices without requiring the caller to scan every
+// cached pod.
+func TestByIndexNamespaceAndNode(t *testing.T) {
+	cache := newSchedulerCache(testContext(), time.Second, time.Second)
+	a := makeBasePod(t, "node-1", "a", "100m", "500", "", nil)
+	b := makeBasePod(t, "node-1", "b", "100m", "500", "", nil)
+	c := makeBasePod(t, "node-2", "c", "100m", "500", "", nil)
+
+	for _, pod := range []*v1.Pod{a, b, c} {
+		if err := cache.AddPod(testContext(), pod); err != nil {
+			t.Fatalf("AddPod(%v) failed: %v", pod.Name, err)
+		}
+	}
+
+	node1Pods, err := cache.ByIndex(ByNodeNameIndex, "node-1")
+	if err != nil {
+		t.Fatalf("ByIndex(byNodeName, node-1) failed: %v", err)
+	}
+	if len(node1Pods) != 2 || !hasPod(node1Pods, "a") || !hasPod(node1Pods, "b") {
+		t.Errorf("expecting [a b] on node-1, got %v", podNames(node1Pods))
+	}
+
+	nsPods, err := cache.ByIndex(ByNamespaceIndex, a.Namespace)
+	if err != nil {
+		t.Fatalf("ByIndex(byNamespace, %v) failed: %v", a.Namespace, err)
+	}
+	if len(nsPods) != 3 {
+		t.Errorf("expecting all 3 pods in namespace %v, got %v", a.Namespace, podNames(nsPods))
+	}
+
+	if _, err := cache.ByIndex("bogus", "x"); err == nil {
+		t.Errorf("expected an error looking up an unregistered index")
+	}
+}
+
+// TestByIndexOwnerUID tests the owner-UID index, including a pod with
+// multiple owner references being filed under each of them.
+func TestByIndexOwnerUID(t *testing.T) {
+	cache := newSchedulerCache(testContext(), time.Second, time.Second)
+	owned := makeBasePod(t, "node-1", "owned", "100m", "500", "", nil)
+	owned.OwnerReferences = []metav1.OwnerReference{
+		{UID: types.UID("owner-a")},
+		{UID: types.UID("owner-b")},
+	}
+	unowned := makeBasePod(t, "node-1", "unowned", "100m", "500", "", nil)
+
+	for _, pod := range []*v1.Pod{owned, unowned} {
+		if err := cache.AddPod(testContext(), pod); err != nil {
+			t.Fatalf("AddPod(%v) failed: %v", pod.Name, err)
+		}
+	}
+
+	for _, owner := range []string{"owner-a", "owner-b"} {
+		pods, err := cache.ByIndex(ByOwnerUIDIndex, owner)
+		if err != nil {
+			t.Fatalf("ByIndex(byOwnerUID, %v) failed: %v", owner, err)
+		}
+		if len(pods) != 1 || pods[0].Name != "owned" {
+			t.Errorf("expecting [owned] for owner %v, got %v", owner, podNames(pods))
+		}
+	}
+}
+
+// TestByIndexUpdatedAcrossPodUpdate tests that moving a pod to a new node via
+// UpdatePod is reflected in the node-name index: the pod stops matching its
+// old node and starts matching its new one.
+func TestByIndexUpdatedAcrossPodUpdate(t *testing.T) {
+	cache := newSchedulerCache(testContext(), time.Second, time.Second)
+	oldPod := makeBasePod(t, "node-1", "p", "100m", "500", "", nil)
+	if err := cache.AddPod(testContext(), oldPod); err != nil {
+		t.Fatalf("AddPod failed: %v", err)
+	}
+
+	newPod := oldPod.DeepCopy()
+	newPod.Spec.NodeName = "node-2"
+	newPod.Labels = map[string]string{"team": "infra"}
+	if err := cache.UpdatePod(testContext(), oldPod, newPod); err != nil {
+		t.Fatalf("UpdatePod failed: %v", err)
+	}
+
+	if pods, err := cache.ByIndex(ByNodeNameIndex, "node-1"); err != nil || len(pods) != 0 {
+		t.Errorf("expecting no pods left on node-1, got %v, err=%v", podNames(pods), err)
+	}
+	pods, err := cache.ByIndex(ByNodeNameIndex, "node-2")
+	if err != nil || len(pods) != 1 || pods[0].Name != "p" {
+		t.Errorf("expecting [p] on node-2, got %v, err=%v", podNames(pods), err)
+	}
+}
+
+// TestByIndexClearedOnRemove tests that removing a pod drops it from the
+// index, not just from podStates.
+func TestByIndexClearedOnRemove(t *testing.T) {
+	cache := newSchedulerCache(testContext(), time.Second, time.Second)
+	pod := makeBasePod(t, "node-1", "p", "100m", "500", "", nil)
+	if err := cache.AddPod(testContext(), pod); err != nil {
+		t.Fatalf("AddPod failed: %v", err)
+	}
+	if err := cache.RemovePod(testContext(), pod); err != nil {
+		t.Fatalf("RemovePod failed: %v", err)
+	}
+
+	pods, err := cache.ByIndex(ByNodeNameIndex, "node-1")
+	if err != nil || len(pods) != 0 {
+		t.Errorf("expecting no pods left on node-1 after removal, got %v, err=%v", podNames(pods), err)
+	}
+}
+
+func makeNamespacedPDB(name, namespace string, minAvailable int) *v1beta1.PodDisruptionBudget {
+	intstrMin := intstr.FromInt(minAvailable)
+	return &v1beta1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec: v1beta1.PodDisruptionBudgetSpec{
+			MinAvailable: &intstrMin,
+			Selector:     &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+		},
+	}
+}
+
+// TestPDBsForPod tests that PDBsForPod matches a pod against only the PDBs
+// in its own namespace, evaluating each one's selector against the pod's
+// labels.
+func TestPDBsForPod(t *testing.T) {
+	cache := newSchedulerCache(testContext(), time.Second, time.Second)
+	matching := makeNamespacedPDB("web-pdb", "ns1", 1)
+	nonMatching := makeNamespacedPDB("other-pdb", "ns1", 1)
+	nonMatching.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}}
+	otherNamespace := makeNamespacedPDB("web-pdb", "ns2", 1)
+
+	for _, pdb := range []*v1beta1.PodDisruptionBudget{matching, nonMatching, otherNamespace} {
+		if err := cache.AddPDB(testContext(), pdb); err != nil {
+			t.Fatalf("AddPDB(%v) failed: %v", pdb.Name, err)
+		}
+	}
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "web-1", Labels: map[string]string{"app": "web"}},
+	}
+	pdbs, err := cache.PDBsForPod(pod)
+	if err != nil {
+		t.Fatalf("PDBsForPod failed: %v", err)
+	}
+	if len(pdbs) != 1 || pdbs[0].Name != "web-pdb" || pdbs[0].Namespace != "ns1" {
+		t.Errorf("expecting only ns1/web-pdb to match, got %v", pdbs)
+	}
+
+	if err := cache.RemovePDB(testContext(), matching); err != nil {
+		t.Fatalf("RemovePDB failed: %v", err)
+	}
+	if pdbs, err := cache.PDBsForPod(pod); err != nil || len(pdbs) != 0 {
+		t.Errorf("expecting no PDBs to match after removal, got %v, err=%v", pdbs, err)
+	}
+}
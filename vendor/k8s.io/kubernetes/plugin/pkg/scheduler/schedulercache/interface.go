@@ -0,0 +1,186 @@
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedulercache
+
+import (
+	"context"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// PodFilter is a function to filter a pod. If pod passed return true else return false.
+type PodFilter func(*v1.Pod) bool
+
+// Cache collects pods' information and provides node-level aggregated information.
+// It's intended for generic scheduler to do efficient lookup.
+// Cache's operations are pod centric. It does incremental updates based on pod events.
+// Pod events are sent via network. We don't have guaranteed delivery of all events:
+// We use Reflector to list and watch from remote.
+// Reflector might be slow and do a relist, which would lead to missing events.
+//
+// State Machine of a pod's events in scheduler's cache:
+//
+//
+//   +-------------------------------------------+  +----+
+//   |                            Add            |  |    |
+//   |                                            |  |    | Update
+//   +      Assume                Add             v  v    |
+// Initial +--------> Assumed +------------+---> Added <--+
+//   ^                  +   +               |       +
+//   |                  |   |               |       |
+//   |                  |   |               +-------+
+//   |                  |   |                Update
+//   +------------------+   +-----------> Expired
+//   Forget                 Expire
+//
+//
+// Note that an assumed pod can expire, because if we haven't received Add event notifying us
+// for a while, there might be some problems and we shouldn't keep the pod in cache anymore.
+//
+// Note that "Initial", "Expired", and "Deleted" pods do not actually exist in cache.
+// Based on existing use cases, we are making the following assumptions:
+// - No pod would be assumed twice
+// - A pod could be added without going through scheduler. In this case, we will see Add but not Assume event.
+// - If a pod wasn't added, it wouldn't be removed or updated.
+// - Both "Expired" and "Deleted" are valid end states. In case of some problems, e.g. network issue,
+//   a pod might have changed its state (e.g. added and deleted) without delivering notification to the cache.
+type Cache interface {
+	// AssumePod assumes a pod scheduled and aggregates the pod's information into its node.
+	// The implementation also decides the policy to expire pod before being confirmed (receiving Add event).
+	// After expiration, its information would be subtracted.
+	AssumePod(ctx context.Context, pod *v1.Pod) error
+
+	// FinishBinding signals that cache for assumed pod can be expired
+	FinishBinding(ctx context.Context, pod *v1.Pod) error
+
+	// ForgetPod removes an assumed pod from cache.
+	ForgetPod(ctx context.Context, pod *v1.Pod) error
+
+	// AddPod either confirms a pod if it's assumed, or adds it back if it's expired.
+	// If added back, the pod's information would be added again.
+	AddPod(ctx context.Context, pod *v1.Pod) error
+
+	// UpdatePod removes oldPod's information and adds newPod's information.
+	UpdatePod(ctx context.Context, oldPod, newPod *v1.Pod) error
+
+	// RemovePod removes a pod. The pod's information would be subtracted from assigned node.
+	RemovePod(ctx context.Context, pod *v1.Pod) error
+
+	// AddForeignPod records a pod's resource usage against its node without
+	// adding it to List/FilteredList or NodeInfo.Pods. It's for workloads that
+	// consume node capacity outside the scheduler's own binding flow, such as
+	// DaemonSets, static pods, or pods bound by an external scheduler.
+	AddForeignPod(ctx context.Context, pod *v1.Pod) error
+
+	// UpdateForeignPod removes oldPod's foreign allocation and adds newPod's.
+	UpdateForeignPod(ctx context.Context, oldPod, newPod *v1.Pod) error
+
+	// RemoveForeignPod reverses AddForeignPod.
+	RemoveForeignPod(ctx context.Context, pod *v1.Pod) error
+
+	// ForeignAllocations returns the UIDs of pods tracked on nodeName as
+	// foreign allocations. See AddForeignPod.
+	ForeignAllocations(nodeName string) []types.UID
+
+	// ByIndex returns the cached pods filed under key for the named index
+	// (ByNamespaceIndex, ByNodeNameIndex, or ByOwnerUIDIndex), without
+	// scanning every cached pod.
+	ByIndex(indexName, key string) ([]*v1.Pod, error)
+
+	// GetPod returns the pod from the cache with the same UID as the
+	// specified pod.
+	GetPod(pod *v1.Pod) (*v1.Pod, error)
+
+	// GetPodByName returns the pod from the cache identified by namespace and
+	// name. Unlike GetPod, it doesn't require a full pod object - useful when
+	// only the namespace/name identity is known, since the cache itself is
+	// now keyed by UID.
+	GetPodByName(namespace, name string) (*v1.Pod, error)
+
+	// IsAssumedPod returns true if the pod is assumed and not expired.
+	IsAssumedPod(pod *v1.Pod) (bool, error)
+
+	// AddNode adds overall information about node.
+	AddNode(ctx context.Context, node *v1.Node) error
+
+	// UpdateNode updates overall information about node.
+	UpdateNode(ctx context.Context, oldNode, newNode *v1.Node) error
+
+	// RemoveNode removes overall information about node.
+	RemoveNode(ctx context.Context, node *v1.Node) error
+
+	// UpdateNodeNameToInfoMap updates the passed infoMap to the current contents of Cache.
+	// The node info contains aggregated information of pods scheduled (including assumed to be)
+	// on this node.
+	UpdateNodeNameToInfoMap(infoMap map[string]*NodeInfo) error
+
+	// List lists all cached pods (including assumed ones) that pass the filter.
+	List(labels.Selector) ([]*v1.Pod, error)
+
+	// FilteredList returns all cached pods that pass the filter and match the selector.
+	FilteredList(filter PodFilter, selector labels.Selector) ([]*v1.Pod, error)
+
+	// AddPDB adds a PodDisruptionBudget object to the cache.
+	AddPDB(ctx context.Context, pdb *v1beta1.PodDisruptionBudget) error
+
+	// UpdatePDB updates a PodDisruptionBudget object in the cache.
+	UpdatePDB(ctx context.Context, oldPDB, newPDB *v1beta1.PodDisruptionBudget) error
+
+	// RemovePDB removes a PodDisruptionBudget object from the cache.
+	RemovePDB(ctx context.Context, pdb *v1beta1.PodDisruptionBudget) error
+
+	// ListPDBs lists all PodDisruptionBudgets in the cache that match the selector.
+	ListPDBs(selector labels.Selector) ([]*v1beta1.PodDisruptionBudget, error)
+
+	// PDBsForPod returns the PodDisruptionBudgets whose selector matches pod.
+	PDBsForPod(pod *v1.Pod) ([]*v1beta1.PodDisruptionBudget, error)
+
+	// AddNodePDB registers pdb as the node PodDisruptionBudget for nodeName,
+	// capping simultaneous disruptions across every pod on that node
+	// regardless of workload label, e.g. for a storage daemon or a
+	// one-replica-per-node service.
+	AddNodePDB(ctx context.Context, nodeName string, pdb *v1beta1.PodDisruptionBudget) error
+
+	// UpdateNodePDB replaces the node PodDisruptionBudget registered for nodeName.
+	UpdateNodePDB(ctx context.Context, nodeName string, pdb *v1beta1.PodDisruptionBudget) error
+
+	// RemoveNodePDB clears the node PodDisruptionBudget registered for nodeName, if any.
+	RemoveNodePDB(ctx context.Context, nodeName string) error
+
+	// NodePDBsForNode returns the node PodDisruptionBudget registered for
+	// nodeName, or nil if it has none.
+	NodePDBsForNode(nodeName string) *v1beta1.PodDisruptionBudget
+
+	// AllowedDisruptions returns how many more pods may be evicted from
+	// nodeName without violating its node PodDisruptionBudget, so preemption
+	// can check node-scoped eviction headroom without re-listing the node's
+	// pods.
+	AllowedDisruptions(nodeName string) int
+
+	// Snapshot serializes the current nodes, pod states, and assumed pods so
+	// they can be persisted and replayed into a fresh process via
+	// RestoreSnapshot, e.g. for a warm scheduler restart.
+	Snapshot() (*CacheSnapshot, error)
+
+	// RestoreSnapshot replaces the cache's contents with those captured by a
+	// prior Snapshot call. Assumed-pod deadlines are re-anchored relative to
+	// the time RestoreSnapshot is called, preserving their remaining TTL.
+	RestoreSnapshot(ctx context.Context, snap *CacheSnapshot) error
+}
@@ -0,0 +1,598 @@
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedulercache
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	priorityutil "k8s.io/kubernetes/plugin/pkg/scheduler/algorithm/priorities/util"
+	schedutil "k8s.io/kubernetes/plugin/pkg/scheduler/util"
+)
+
+// NodeInfo is node level aggregated information.
+type NodeInfo struct {
+	// Overall node information.
+	node *v1.Node
+
+	pods []*v1.Pod
+
+	// foreignPods tracks pods whose resource usage counts against this node's
+	// requestedResource/nonzeroRequest but that aren't scheduler-owned, e.g.
+	// DaemonSets, static pods, or pods bound by an external scheduler. They're
+	// keyed the same way as pods (by pod key) but intentionally excluded from
+	// Pods(), since preemption and PDB math only reason about pods the
+	// scheduler itself can reschedule or evict.
+	foreignPods map[string]types.UID
+
+	// usedPorts holds the set of all ports, keyed by "protocol/ip/port", that
+	// have been claimed by a container's HostPort on this node.
+	usedPorts map[string]bool
+
+	// Total requested resource of all pods on this node.
+	// It includes assumed pods which scheduler sends binding to apiserver but
+	// didn't get it as scheduled yet.
+	requestedResource *Resource
+	// Total requested resources of all pods on this node plus default resources
+	// for pods that don't specify requests.
+	// This is used to avoid scheduling on host with mostly unspecified resources.
+	nonzeroRequest *Resource
+	// We store allocatedResources (which is Node.Status.Allocatable.*) explicitly
+	// as int64, to avoid conversions and accessing map.
+	allocatableResource *Resource
+
+	// Cached taints of the node for faster lookup.
+	taints []v1.Taint
+
+	// nodePDB is the PodDisruptionBudget, if any, capping simultaneous
+	// disruptions across every pod on this node regardless of workload label,
+	// e.g. for a storage daemon or a one-replica-per-node service. See
+	// AllowedDisruptions.
+	nodePDB *v1beta1.PodDisruptionBudget
+
+	// Whenever NodeInfo changes, generation is bumped.
+	// This is used to avoid cloning it if the object didn't change.
+	generation int64
+}
+
+// Resource is a collection of compute resources.
+type Resource struct {
+	MilliCPU        int64
+	Memory          int64
+	ScalarResources map[v1.ResourceName]int64
+}
+
+// NewResource creates a Resource from ResourceList
+func NewResource(rl v1.ResourceList) *Resource {
+	r := &Resource{}
+	r.Add(rl)
+	return r
+}
+
+// Add adds ResourceList into Resource.
+func (r *Resource) Add(rl v1.ResourceList) {
+	if r == nil {
+		return
+	}
+	for rName, rQuant := range rl {
+		switch rName {
+		case v1.ResourceCPU:
+			r.MilliCPU += rQuant.MilliValue()
+		case v1.ResourceMemory:
+			r.Memory += rQuant.Value()
+		default:
+			if isScalarResourceName(rName) {
+				r.AddScalar(rName, rQuant.Value())
+			}
+		}
+	}
+}
+
+// ResourceList returns a resource list of this resource.
+func (r *Resource) ResourceList() v1.ResourceList {
+	result := v1.ResourceList{
+		v1.ResourceCPU:    *resource.NewMilliQuantity(r.MilliCPU, resource.DecimalSI),
+		v1.ResourceMemory: *resource.NewQuantity(r.Memory, resource.BinarySI),
+	}
+	for rName, rQuant := range r.ScalarResources {
+		result[rName] = *resource.NewQuantity(rQuant, resource.DecimalSI)
+	}
+	return result
+}
+
+// AddScalar adds a resource by a scalar value of the named resource.
+func (r *Resource) AddScalar(name v1.ResourceName, quantity int64) {
+	if r.ScalarResources == nil {
+		r.ScalarResources = map[v1.ResourceName]int64{}
+	}
+	r.ScalarResources[name] += quantity
+}
+
+// Clone returns a copy of r that shares no state with it: mutating the
+// clone's ScalarResources (e.g. via AddScalar) never affects r's.
+func (r *Resource) Clone() *Resource {
+	clone := &Resource{MilliCPU: r.MilliCPU, Memory: r.Memory}
+	if len(r.ScalarResources) > 0 {
+		clone.ScalarResources = make(map[v1.ResourceName]int64, len(r.ScalarResources))
+		for k, v := range r.ScalarResources {
+			clone.ScalarResources[k] = v
+		}
+	}
+	return clone
+}
+
+// isScalarResourceName validates the resource for a pod, node, etc. and returns
+// true if it's an extended/scalar resource (as opposed to CPU or memory, which
+// are tracked natively).
+func isScalarResourceName(name v1.ResourceName) bool {
+	return strings.Contains(string(name), "/")
+}
+
+// NewNodeInfo returns a ready to use empty NodeInfo object.
+// If any pods are given in arguments, their information will be aggregated in
+// the returned object.
+func NewNodeInfo(pods ...*v1.Pod) *NodeInfo {
+	ni := &NodeInfo{
+		requestedResource:   &Resource{},
+		nonzeroRequest:      &Resource{},
+		allocatableResource: &Resource{},
+		usedPorts:           make(map[string]bool),
+		generation:          0,
+	}
+	for _, pod := range pods {
+		ni.AddPod(pod)
+	}
+	return ni
+}
+
+// Node returns overall information about this node.
+func (n *NodeInfo) Node() *v1.Node {
+	if n == nil {
+		return nil
+	}
+	return n.node
+}
+
+// nodeName returns n.node's name for use in error messages, or "<unknown>"
+// if n.node hasn't been observed yet, e.g. a pod was assumed/added on a node
+// before its Node object arrived via AddNode/UpdateNode.
+func (n *NodeInfo) nodeName() string {
+	if n.node == nil {
+		return "<unknown>"
+	}
+	return n.node.Name
+}
+
+// Pods return all pods scheduled (including assumed to be) on this node.
+func (n *NodeInfo) Pods() []*v1.Pod {
+	if n == nil {
+		return nil
+	}
+	return n.pods
+}
+
+// UsedPorts returns the ports claimed by pods on this node.
+func (n *NodeInfo) UsedPorts() map[string]bool {
+	if n == nil {
+		return nil
+	}
+	return n.usedPorts
+}
+
+// RequestedResource returns aggregated resource request of pods on this node.
+func (n *NodeInfo) RequestedResource() Resource {
+	if n == nil {
+		return Resource{}
+	}
+	return *n.requestedResource
+}
+
+// NonZeroRequest returns aggregated nonzero resource request of pods on this node.
+func (n *NodeInfo) NonZeroRequest() Resource {
+	if n == nil {
+		return Resource{}
+	}
+	return *n.nonzeroRequest
+}
+
+// AllocatableResource returns allocatable resources on a given node.
+func (n *NodeInfo) AllocatableResource() Resource {
+	if n == nil {
+		return Resource{}
+	}
+	return *n.allocatableResource
+}
+
+// Taints returns the taints list on this node.
+func (n *NodeInfo) Taints() ([]v1.Taint, error) {
+	if n == nil {
+		return nil, nil
+	}
+	return n.taints, nil
+}
+
+// Generation returns the generation on this node.
+func (n *NodeInfo) Generation() int64 {
+	if n == nil {
+		return 0
+	}
+	return n.generation
+}
+
+// ForeignAllocations returns the UIDs of pods whose resource usage is
+// accounted against this node but that aren't scheduler-owned, e.g.
+// DaemonSets, static pods, or pods bound by an external scheduler. See
+// AddForeignPod.
+func (n *NodeInfo) ForeignAllocations() []types.UID {
+	if n == nil || len(n.foreignPods) == 0 {
+		return nil
+	}
+	uids := make([]types.UID, 0, len(n.foreignPods))
+	for _, uid := range n.foreignPods {
+		uids = append(uids, uid)
+	}
+	return uids
+}
+
+// SetNodePDB registers (or, if pdb is nil, clears) the PodDisruptionBudget
+// capping simultaneous disruptions across every pod on this node. See
+// AllowedDisruptions.
+func (n *NodeInfo) SetNodePDB(pdb *v1beta1.PodDisruptionBudget) {
+	n.nodePDB = pdb
+	n.generation++
+}
+
+// AllowedDisruptions returns how many more of this node's pods may be
+// evicted without violating its node PodDisruptionBudget, so preemption can
+// check node-scoped eviction headroom without re-listing the node's pods. It
+// returns -1 if the node has no node PDB registered, meaning disruptions on
+// it aren't constrained by this mechanism.
+func (n *NodeInfo) AllowedDisruptions() int {
+	if n == nil || n.nodePDB == nil {
+		return -1
+	}
+	total := len(n.pods)
+	var minAvailable int
+	switch {
+	case n.nodePDB.Spec.MinAvailable != nil:
+		minAvailable, _ = intstr.GetScaledValueFromIntOrPercent(n.nodePDB.Spec.MinAvailable, total, true)
+	case n.nodePDB.Spec.MaxUnavailable != nil:
+		maxUnavailable, _ := intstr.GetScaledValueFromIntOrPercent(n.nodePDB.Spec.MaxUnavailable, total, true)
+		minAvailable = total - maxUnavailable
+	}
+	if allowed := total - minAvailable; allowed > 0 {
+		return allowed
+	}
+	return 0
+}
+
+// Clone returns a copy of this node.
+func (n *NodeInfo) Clone() *NodeInfo {
+	clone := &NodeInfo{
+		node:                n.node,
+		requestedResource:   n.requestedResource.Clone(),
+		nonzeroRequest:      n.nonzeroRequest.Clone(),
+		allocatableResource: n.allocatableResource.Clone(),
+		taints:              append([]v1.Taint(nil), n.taints...),
+		nodePDB:             n.nodePDB,
+		usedPorts:           make(map[string]bool, len(n.usedPorts)),
+		generation:          n.generation,
+	}
+	if len(n.pods) > 0 {
+		clone.pods = append([]*v1.Pod(nil), n.pods...)
+	}
+	if len(n.usedPorts) > 0 {
+		for k, v := range n.usedPorts {
+			clone.usedPorts[k] = v
+		}
+	}
+	if len(n.foreignPods) > 0 {
+		clone.foreignPods = make(map[string]types.UID, len(n.foreignPods))
+		for k, v := range n.foreignPods {
+			clone.foreignPods[k] = v
+		}
+	}
+	return clone
+}
+
+// String returns representation of human readable format of this NodeInfo.
+func (n *NodeInfo) String() string {
+	podKeys := make([]string, len(n.pods))
+	for i, pod := range n.pods {
+		podKeys[i] = pod.Name
+	}
+	return fmt.Sprintf("&NodeInfo{Pods:%v, ForeignPods:%d, RequestedResource:%#v, NonZeroRequest:%#v, UsedPort: %#v}",
+		podKeys, len(n.foreignPods), n.requestedResource, n.nonzeroRequest, n.usedPorts)
+}
+
+// addPodPorts merges the HostPort usage of pod into usedPorts.
+func (n *NodeInfo) addPodPorts(pod *v1.Pod) {
+	if n.usedPorts == nil {
+		n.usedPorts = make(map[string]bool)
+	}
+	for k, v := range schedutil.GetUsedPorts(pod) {
+		n.usedPorts[k] = v
+	}
+}
+
+// removePodPorts clears the HostPort usage claimed by pod from usedPorts.
+func (n *NodeInfo) removePodPorts(pod *v1.Pod) {
+	for k := range schedutil.GetUsedPorts(pod) {
+		delete(n.usedPorts, k)
+	}
+}
+
+// AddPod adds pod information to this NodeInfo.
+func (n *NodeInfo) AddPod(pod *v1.Pod) {
+	res, non0CPU, non0Mem := calculateResource(pod)
+	n.requestedResource.MilliCPU += res.MilliCPU
+	n.requestedResource.Memory += res.Memory
+	for rName, rQuant := range res.ScalarResources {
+		n.requestedResource.AddScalar(rName, rQuant)
+	}
+	n.nonzeroRequest.MilliCPU += non0CPU
+	n.nonzeroRequest.Memory += non0Mem
+	n.pods = append(n.pods, pod)
+	n.addPodPorts(pod)
+	n.generation++
+}
+
+// RemovePod subtracts pod information from this NodeInfo.
+func (n *NodeInfo) RemovePod(pod *v1.Pod) error {
+	k1, err := getPodKey(pod)
+	if err != nil {
+		return err
+	}
+
+	for i := range n.pods {
+		k2, err := getPodKey(n.pods[i])
+		if err != nil {
+			continue
+		}
+		if k1 == k2 {
+			// delete the element
+			n.pods[i] = n.pods[len(n.pods)-1]
+			n.pods = n.pods[:len(n.pods)-1]
+
+			res, non0CPU, non0Mem := calculateResource(pod)
+			n.requestedResource.MilliCPU -= res.MilliCPU
+			n.requestedResource.Memory -= res.Memory
+			for rName, rQuant := range res.ScalarResources {
+				if n.requestedResource.ScalarResources != nil {
+					n.requestedResource.ScalarResources[rName] -= rQuant
+				}
+			}
+			n.nonzeroRequest.MilliCPU -= non0CPU
+			n.nonzeroRequest.Memory -= non0Mem
+			n.removePodPorts(pod)
+			n.generation++
+			return nil
+		}
+	}
+	return fmt.Errorf("no corresponding pod %s in pods of node %s", pod.Name, n.nodeName())
+}
+
+// updatePodObject swaps the cached pod object matching pod's key for pod
+// itself, without touching the resource/port accounting already applied for
+// it. It's used when a pod's identity (namespace/name/UID) is unchanged but
+// its object has been refreshed, e.g. AddPod confirming a previously assumed
+// pod with the kubelet-observed copy.
+func (n *NodeInfo) updatePodObject(pod *v1.Pod) {
+	key, err := getPodKey(pod)
+	if err != nil {
+		return
+	}
+	for i := range n.pods {
+		k2, err := getPodKey(n.pods[i])
+		if err != nil {
+			continue
+		}
+		if key == k2 {
+			n.pods[i] = pod
+			return
+		}
+	}
+}
+
+// isForeignPod reports whether pod is currently tracked as a foreign
+// allocation on n. See AddForeignPod.
+func (n *NodeInfo) isForeignPod(pod *v1.Pod) bool {
+	key, err := getPodKey(pod)
+	if err != nil {
+		return false
+	}
+	_, ok := n.foreignPods[key]
+	return ok
+}
+
+// promoteForeignPod moves pod from n's foreign allocations into n.Pods(),
+// without touching its already-accounted resource usage or port claims - it's
+// called when the scheduler itself binds a pod that was previously only
+// known to n as a foreign allocation.
+func (n *NodeInfo) promoteForeignPod(pod *v1.Pod) {
+	key, err := getPodKey(pod)
+	if err != nil {
+		return
+	}
+	delete(n.foreignPods, key)
+	n.pods = append(n.pods, pod)
+	n.addPodPorts(pod)
+	n.generation++
+}
+
+// AddForeignPod records pod's resource usage against n without adding it to
+// n.Pods(): used for workloads that consume node capacity outside the
+// scheduler's own AssumePod/AddPod flow, such as DaemonSets, static pods, or
+// pods bound by an external scheduler. It's a no-op if pod is already tracked,
+// so callers can't double-count its resources by calling it twice.
+func (n *NodeInfo) AddForeignPod(pod *v1.Pod) error {
+	key, err := getPodKey(pod)
+	if err != nil {
+		return err
+	}
+	if _, ok := n.foreignPods[key]; ok {
+		return nil
+	}
+	res, non0CPU, non0Mem := calculateResource(pod)
+	n.requestedResource.MilliCPU += res.MilliCPU
+	n.requestedResource.Memory += res.Memory
+	for rName, rQuant := range res.ScalarResources {
+		n.requestedResource.AddScalar(rName, rQuant)
+	}
+	n.nonzeroRequest.MilliCPU += non0CPU
+	n.nonzeroRequest.Memory += non0Mem
+	n.addPodPorts(pod)
+	if n.foreignPods == nil {
+		n.foreignPods = make(map[string]types.UID)
+	}
+	n.foreignPods[key] = pod.UID
+	n.generation++
+	return nil
+}
+
+// RemoveForeignPod reverses AddForeignPod, subtracting pod's resource usage
+// from n.
+func (n *NodeInfo) RemoveForeignPod(pod *v1.Pod) error {
+	key, err := getPodKey(pod)
+	if err != nil {
+		return err
+	}
+	if _, ok := n.foreignPods[key]; !ok {
+		return fmt.Errorf("no corresponding foreign pod %s on node %s", pod.Name, n.nodeName())
+	}
+
+	res, non0CPU, non0Mem := calculateResource(pod)
+	n.requestedResource.MilliCPU -= res.MilliCPU
+	n.requestedResource.Memory -= res.Memory
+	for rName, rQuant := range res.ScalarResources {
+		if n.requestedResource.ScalarResources != nil {
+			n.requestedResource.ScalarResources[rName] -= rQuant
+		}
+	}
+	n.nonzeroRequest.MilliCPU -= non0CPU
+	n.nonzeroRequest.Memory -= non0Mem
+	n.removePodPorts(pod)
+	delete(n.foreignPods, key)
+	n.generation++
+	return nil
+}
+
+// Conflicts reports the dimensions on which placing pod on this node would
+// collide with the node's existing allocation: a HostIP/Protocol/HostPort
+// tuple already claimed by another pod, or a requested resource that would
+// push the node's total past what's allocatable. It does not mutate n.
+func (n *NodeInfo) Conflicts(pod *v1.Pod) []Conflict {
+	var conflicts []Conflict
+
+	for portKey := range schedutil.GetUsedPorts(pod) {
+		if n.usedPorts[portKey] {
+			conflicts = append(conflicts, Conflict{
+				Reason:         HostPortConflict,
+				NewPod:         pod,
+				ConflictingPod: n.findPodUsingPort(portKey),
+			})
+		}
+	}
+
+	res, _, _ := calculateResource(pod)
+	if n.allocatableResource.MilliCPU > 0 && n.requestedResource.MilliCPU+res.MilliCPU > n.allocatableResource.MilliCPU {
+		conflicts = append(conflicts, Conflict{
+			Reason:      CPUConflict,
+			NewPod:      pod,
+			Requested:   n.requestedResource.MilliCPU + res.MilliCPU,
+			Allocatable: n.allocatableResource.MilliCPU,
+		})
+	}
+	if n.allocatableResource.Memory > 0 && n.requestedResource.Memory+res.Memory > n.allocatableResource.Memory {
+		conflicts = append(conflicts, Conflict{
+			Reason:      MemoryConflict,
+			NewPod:      pod,
+			Requested:   n.requestedResource.Memory + res.Memory,
+			Allocatable: n.allocatableResource.Memory,
+		})
+	}
+	for rName, rQuant := range res.ScalarResources {
+		allocatable, ok := n.allocatableResource.ScalarResources[rName]
+		if ok && n.requestedResource.ScalarResources[rName]+rQuant > allocatable {
+			conflicts = append(conflicts, Conflict{
+				Reason:       ScalarResourceConflict,
+				NewPod:       pod,
+				ResourceName: rName,
+				Requested:    n.requestedResource.ScalarResources[rName] + rQuant,
+				Allocatable:  allocatable,
+			})
+		}
+	}
+	return conflicts
+}
+
+// findPodUsingPort returns the pod already on this node that's holding the
+// given "protocol/ip/port" key, if any.
+func (n *NodeInfo) findPodUsingPort(portKey string) *v1.Pod {
+	for _, p := range n.pods {
+		if schedutil.GetUsedPorts(p)[portKey] {
+			return p
+		}
+	}
+	return nil
+}
+
+func calculateResource(pod *v1.Pod) (res Resource, non0CPU int64, non0Mem int64) {
+	for _, c := range pod.Spec.Containers {
+		res.Add(c.Resources.Requests)
+		non0CPUReq, non0MemReq := priorityutil.GetNonzeroRequests(&c.Resources.Requests)
+		non0CPU += non0CPUReq
+		non0Mem += non0MemReq
+	}
+	return
+}
+
+// SetNode sets the overall node information.
+func (n *NodeInfo) SetNode(node *v1.Node) error {
+	n.node = node
+	n.allocatableResource = NewResource(node.Status.Allocatable)
+	n.taints = node.Spec.Taints
+	n.generation++
+	return nil
+}
+
+// RemoveNode removes the overall information about the node.
+func (n *NodeInfo) RemoveNode(node *v1.Node) error {
+	n.node = nil
+	n.allocatableResource = &Resource{}
+	n.taints = nil
+	n.generation++
+	return nil
+}
+
+// getPodKey returns the identity of a pod as used to index it within a
+// NodeInfo's pod list and the cache's podStates map. Pods are identified by
+// UID rather than namespace/name: if a pod is deleted and a new pod reusing
+// the same namespace/name is created before the old entry has been reclaimed,
+// keying by UID keeps the two lifecycles from being confused with each
+// other.
+func getPodKey(pod *v1.Pod) (string, error) {
+	if len(pod.UID) == 0 {
+		return "", fmt.Errorf("cannot get cache key for pod with empty UID")
+	}
+	return string(pod.UID), nil
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedulercache
+
+import "k8s.io/api/core/v1"
+
+// CacheEvent carries the state an observer needs after a schedulerCache
+// mutation: the pod involved, the node it's on, that node's resulting
+// requested/allocatable resource, and how many pods are currently assumed
+// cache-wide.
+type CacheEvent struct {
+	Pod                 *v1.Pod
+	Node                string
+	RequestedResource   Resource
+	AllocatableResource Resource
+	AssumedPods         int
+}
+
+// CacheObserver is notified of schedulerCache lifecycle events after state
+// has already been mutated. Observers are called synchronously, in
+// registration order, while the cache's internal lock is held - they must
+// not call back into the Cache they're observing.
+type CacheObserver interface {
+	// OnAssume fires when a pod is tentatively placed via AssumePod.
+	OnAssume(event CacheEvent)
+	// OnFinishBinding fires when an assumed pod's binding completes and its
+	// expiration deadline is (re-)anchored.
+	OnFinishBinding(event CacheEvent)
+	// OnAdd fires when a pod is confirmed (AddPod), whether it was
+	// previously assumed or is being added back after expiring.
+	OnAdd(event CacheEvent)
+	// OnUpdate fires when an already-added pod is updated in place.
+	OnUpdate(oldPod *v1.Pod, event CacheEvent)
+	// OnRemove fires when a confirmed pod is removed.
+	OnRemove(event CacheEvent)
+	// OnForget fires when an assumed pod is explicitly reclaimed via
+	// ForgetPod, before its ttl (if any) would have expired it.
+	OnForget(event CacheEvent)
+	// OnExpire fires when cleanupAssumedPods reclaims an assumed pod whose
+	// deadline has passed. reason is always "ttl" today but is passed
+	// through so future expiration sources don't require an interface
+	// change.
+	OnExpire(event CacheEvent, reason string)
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedulercache
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+)
+
+// recordingObserver records which callback fired, tagged with a name, so
+// tests can assert both occurrence and relative ordering.
+type recordingObserver struct {
+	name  string
+	calls *[]string
+}
+
+func (o *recordingObserver) record(verb string) { *o.calls = append(*o.calls, o.name+":"+verb) }
+
+func (o *recordingObserver) OnAssume(event CacheEvent)            { o.record("assume") }
+func (o *recordingObserver) OnFinishBinding(event CacheEvent)     { o.record("finishBinding") }
+func (o *recordingObserver) OnAdd(event CacheEvent)               { o.record("add") }
+func (o *recordingObserver) OnUpdate(_ *v1.Pod, event CacheEvent) { o.record("update") }
+func (o *recordingObserver) OnRemove(event CacheEvent)            { o.record("remove") }
+func (o *recordingObserver) OnForget(event CacheEvent)            { o.record("forget") }
+func (o *recordingObserver) OnExpire(event CacheEvent, reason string) {
+	o.record("expire:" + reason)
+}
+
+// TestObserverOrdering tests that observers are notified in registration
+// order for a single mutation.
+func TestObserverOrdering(t *testing.T) {
+	var calls []string
+	first := &recordingObserver{name: "first", calls: &calls}
+	second := &recordingObserver{name: "second", calls: &calls}
+
+	cache := newSchedulerCache(testContext(), time.Second, time.Second, first, second)
+	pod := makeBasePod(t, "node", "test-1", "100m", "500", "", nil)
+	if err := cache.AssumePod(testContext(), pod); err != nil {
+		t.Fatalf("AssumePod failed: %v", err)
+	}
+
+	want := []string{"first:assume", "second:assume"}
+	if len(calls) != len(want) {
+		t.Fatalf("got calls %v, want %v", calls, want)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Errorf("call %d = %q, want %q", i, calls[i], want[i])
+		}
+	}
+}
+
+// TestObserverExpireFiresOnce tests that an expiring assumed pod triggers
+// exactly one OnExpire call, with reason "ttl".
+func TestObserverExpireFiresOnce(t *testing.T) {
+	var calls []string
+	obs := &recordingObserver{name: "obs", calls: &calls}
+
+	ttl := 10 * time.Second
+	now := time.Now()
+	cache := newSchedulerCache(testContext(), ttl, time.Second, obs)
+	pod := makeBasePod(t, "node", "test-1", "100m", "500", "", nil)
+	if err := assumeAndFinishBinding(cache, pod, now); err != nil {
+		t.Fatalf("assumePod failed: %v", err)
+	}
+
+	cache.cleanupAssumedPods(testContext(), now.Add(2 * ttl))
+
+	expireCalls := 0
+	for _, c := range calls {
+		if c == "obs:expire:ttl" {
+			expireCalls++
+		}
+	}
+	if expireCalls != 1 {
+		t.Errorf("got %d OnExpire calls with reason ttl (calls=%v), want exactly 1", expireCalls, calls)
+	}
+}
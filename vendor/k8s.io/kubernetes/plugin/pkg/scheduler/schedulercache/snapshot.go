@@ -0,0 +1,251 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedulercache
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+// ResourceSnapshot is the JSON-serializable form of Resource.
+type ResourceSnapshot struct {
+	MilliCPU        int64                      `json:"milliCPU"`
+	Memory          int64                      `json:"memory"`
+	ScalarResources map[v1.ResourceName]int64 `json:"scalarResources,omitempty"`
+}
+
+func toResourceSnapshot(r *Resource) ResourceSnapshot {
+	return ResourceSnapshot{
+		MilliCPU:        r.MilliCPU,
+		Memory:          r.Memory,
+		ScalarResources: r.Clone().ScalarResources,
+	}
+}
+
+func (s ResourceSnapshot) toResource() *Resource {
+	return &Resource{
+		MilliCPU:        s.MilliCPU,
+		Memory:          s.Memory,
+		ScalarResources: s.ScalarResources,
+	}
+}
+
+// NodeInfoSnapshot is the JSON-serializable form of a NodeInfo. Pods are
+// referenced by UID rather than embedded, since the same pod object is also
+// recorded once in CacheSnapshot.Pods.
+type NodeInfoSnapshot struct {
+	Node                *v1.Node         `json:"node,omitempty"`
+	RequestedResource   ResourceSnapshot `json:"requestedResource"`
+	NonZeroRequest      ResourceSnapshot `json:"nonzeroRequest"`
+	AllocatableResource ResourceSnapshot `json:"allocatableResource"`
+	Taints              []v1.Taint       `json:"taints,omitempty"`
+	UsedPorts           map[string]bool  `json:"usedPorts,omitempty"`
+	PodUIDs             []types.UID      `json:"podUIDs,omitempty"`
+}
+
+// PodStateSnapshot is the JSON-serializable form of a podState.
+type PodStateSnapshot struct {
+	Pod             *v1.Pod    `json:"pod"`
+	Assumed         bool       `json:"assumed"`
+	AssumedTime     time.Time  `json:"assumedTime,omitempty"`
+	Deadline        *time.Time `json:"deadline,omitempty"`
+	BindingFinished bool       `json:"bindingFinished"`
+}
+
+// CacheSnapshot is a point-in-time, JSON-serializable capture of a
+// schedulerCache's nodes, podStates, assumedPods, PDBs, and node PDBs,
+// suitable for persisting to disk and replaying via RestoreSnapshot.
+type CacheSnapshot struct {
+	Nodes map[string]*NodeInfoSnapshot `json:"nodes"`
+	// Pods is keyed the same way as schedulerCache.podStates (pod UID).
+	Pods map[string]*PodStateSnapshot `json:"pods"`
+	// PDBs is keyed the same way as schedulerCache.pdbs ("namespace/name").
+	PDBs map[string]*v1beta1.PodDisruptionBudget `json:"pdbs,omitempty"`
+	// NodePDBs is keyed by node name, the same way as schedulerCache.nodePDBs.
+	NodePDBs map[string]*v1beta1.PodDisruptionBudget `json:"nodePDBs,omitempty"`
+	// TakenAt is when the snapshot was captured; RestoreSnapshot uses it to
+	// compute each assumed pod's remaining TTL at restore time.
+	TakenAt time.Time `json:"takenAt"`
+}
+
+// ToJSON marshals the snapshot to JSON.
+func (s *CacheSnapshot) ToJSON() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+// CacheSnapshotFromJSON unmarshals a snapshot previously produced by ToJSON.
+func CacheSnapshotFromJSON(data []byte) (*CacheSnapshot, error) {
+	snap := &CacheSnapshot{}
+	if err := json.Unmarshal(data, snap); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// Snapshot captures the current nodes, podStates, assumedPods, PDBs, and
+// node PDBs.
+func (cache *schedulerCache) Snapshot() (*CacheSnapshot, error) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	snap := &CacheSnapshot{
+		Nodes:    make(map[string]*NodeInfoSnapshot, len(cache.nodes)),
+		Pods:     make(map[string]*PodStateSnapshot, len(cache.podStates)),
+		PDBs:     make(map[string]*v1beta1.PodDisruptionBudget, len(cache.pdbs)),
+		NodePDBs: make(map[string]*v1beta1.PodDisruptionBudget, len(cache.nodePDBs)),
+		TakenAt:  time.Now(),
+	}
+	for name, item := range cache.nodes {
+		n := item.info
+		podUIDs := make([]types.UID, 0, len(n.pods))
+		for _, p := range n.pods {
+			podUIDs = append(podUIDs, p.UID)
+		}
+		usedPorts := make(map[string]bool, len(n.usedPorts))
+		for k, v := range n.usedPorts {
+			usedPorts[k] = v
+		}
+		snap.Nodes[name] = &NodeInfoSnapshot{
+			Node:                n.node,
+			RequestedResource:   toResourceSnapshot(n.requestedResource),
+			NonZeroRequest:      toResourceSnapshot(n.nonzeroRequest),
+			AllocatableResource: toResourceSnapshot(n.allocatableResource),
+			Taints:              n.taints,
+			UsedPorts:           usedPorts,
+			PodUIDs:             podUIDs,
+		}
+	}
+	for key, ps := range cache.podStates {
+		snap.Pods[key] = &PodStateSnapshot{
+			Pod:             ps.pod,
+			Assumed:         cache.assumedPods[key],
+			AssumedTime:     ps.assumedTime,
+			Deadline:        ps.deadline,
+			BindingFinished: ps.bindingFinished,
+		}
+	}
+	for key, pdb := range cache.pdbs {
+		snap.PDBs[key] = pdb
+	}
+	for name, pdb := range cache.nodePDBs {
+		snap.NodePDBs[name] = pdb
+	}
+	return snap, nil
+}
+
+// RestoreSnapshot replaces the cache's contents with snap, re-anchoring
+// assumed-pod deadlines to time.Now() plus their remaining TTL at the time
+// the snapshot was taken.
+func (cache *schedulerCache) RestoreSnapshot(ctx context.Context, snap *CacheSnapshot) error {
+	klog.FromContext(ctx).V(3).Info("Restoring cache from snapshot", "nodes", len(snap.Nodes), "pods", len(snap.Pods))
+	return cache.restoreSnapshot(snap, time.Now())
+}
+
+// restoreSnapshot exists to make tests deterministic by taking the restore
+// time as an input argument.
+func (cache *schedulerCache) restoreSnapshot(snap *CacheSnapshot, restoreTime time.Time) error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	podsByUID := make(map[types.UID]*v1.Pod, len(snap.Pods))
+	podStates := make(map[string]*podState, len(snap.Pods))
+	assumedPods := make(map[string]bool, len(snap.Pods))
+	byNamespaceName := make(map[string]types.UID, len(snap.Pods))
+	podIdx := newPodIndex(podIndexers)
+
+	for key, ps := range snap.Pods {
+		podsByUID[ps.Pod.UID] = ps.Pod
+		byNamespaceName[nameKey(ps.Pod)] = ps.Pod.UID
+		podIdx.add(key, ps.Pod)
+
+		restored := &podState{
+			pod:             ps.Pod,
+			bindingFinished: ps.BindingFinished,
+			assumedTime:     ps.AssumedTime,
+		}
+		if ps.Deadline != nil {
+			remaining := ps.Deadline.Sub(snap.TakenAt)
+			if remaining < 0 {
+				remaining = 0
+			}
+			deadline := restoreTime.Add(remaining)
+			restored.deadline = &deadline
+		}
+		podStates[key] = restored
+		if ps.Assumed {
+			assumedPods[key] = true
+		}
+	}
+
+	pdbs := make(map[string]*v1beta1.PodDisruptionBudget, len(snap.PDBs))
+	pdbIdx := newPDBIndex()
+	for key, pdb := range snap.PDBs {
+		pdbs[key] = pdb
+		pdbIdx.add(key, pdb)
+	}
+	nodePDBs := make(map[string]*v1beta1.PodDisruptionBudget, len(snap.NodePDBs))
+	for name, pdb := range snap.NodePDBs {
+		nodePDBs[name] = pdb
+	}
+	cache.pdbs = pdbs
+	cache.pdbIndex = pdbIdx
+	cache.nodePDBs = nodePDBs
+
+	// Tombstone every node cached before the restore, and every restored one
+	// below, so the next UpdateNodeNameToInfoMap prunes the caller's
+	// pre-restore entries instead of stopping at one whose generation happens
+	// to match.
+	for name := range cache.nodes {
+		cache.removedNodeNames = append(cache.removedNodeNames, name)
+	}
+	cache.nodes = make(map[string]*nodeInfoListItem, len(snap.Nodes))
+	cache.headNode = nil
+	for name, ns := range snap.Nodes {
+		cache.removedNodeNames = append(cache.removedNodeNames, name)
+		n := NewNodeInfo()
+		n.node = ns.Node
+		n.requestedResource = ns.RequestedResource.toResource()
+		n.nonzeroRequest = ns.NonZeroRequest.toResource()
+		n.allocatableResource = ns.AllocatableResource.toResource()
+		n.taints = ns.Taints
+		n.usedPorts = ns.UsedPorts
+		n.nodePDB = cache.nodePDBs[name]
+		for _, uid := range ns.PodUIDs {
+			if p, ok := podsByUID[uid]; ok {
+				n.pods = append(n.pods, p)
+			}
+		}
+		// The generation counter is reset consistently on restore: every
+		// restored node starts at generation 1, as if freshly observed once,
+		// so UpdateNodeNameToInfoMap callers reliably re-clone it.
+		n.generation = 1
+		cache.nodes[name] = &nodeInfoListItem{name: name, info: n}
+		cache.moveNodeInfoToHead(name)
+	}
+
+	cache.podStates = podStates
+	cache.assumedPods = assumedPods
+	cache.byNamespaceName = byNamespaceName
+	cache.podIndex = podIdx
+	return nil
+}
@@ -0,0 +1,189 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedulercache
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// TestSnapshotRestoreRoundTrip tests that a node's aggregated information
+// survives a Snapshot followed by a RestoreSnapshot into a fresh cache,
+// modeled on the node-info comparison in TestNodeOperators.
+func TestSnapshotRestoreRoundTrip(t *testing.T) {
+	nodeName := "test-node"
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: nodeName},
+		Status: v1.NodeStatus{
+			Allocatable: v1.ResourceList{
+				v1.ResourceCPU:    resource.MustParse("1000m"),
+				v1.ResourceMemory: resource.MustParse("100m"),
+			},
+		},
+		Spec: v1.NodeSpec{
+			Taints: []v1.Taint{{Key: "test-key", Value: "test-value", Effect: v1.TaintEffectPreferNoSchedule}},
+		},
+	}
+	pods := []*v1.Pod{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "pod1", UID: types.UID("pod1")},
+			Spec: v1.PodSpec{
+				NodeName: nodeName,
+				Containers: []v1.Container{{
+					Resources: v1.ResourceRequirements{
+						Requests: v1.ResourceList{
+							v1.ResourceCPU:    resource.MustParse("500m"),
+							v1.ResourceMemory: resource.MustParse("50m"),
+						},
+					},
+				}},
+			},
+		},
+	}
+	expected := buildNodeInfo(node, pods)
+
+	src := newSchedulerCache(testContext(), time.Second, time.Second)
+	if err := src.AddNode(testContext(), node); err != nil {
+		t.Fatalf("AddNode failed: %v", err)
+	}
+	for _, pod := range pods {
+		if err := src.AddPod(testContext(), pod); err != nil {
+			t.Fatalf("AddPod failed: %v", err)
+		}
+	}
+
+	snap, err := src.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+
+	dst := newSchedulerCache(testContext(), time.Second, time.Second)
+	if err := dst.RestoreSnapshot(testContext(), snap); err != nil {
+		t.Fatalf("RestoreSnapshot failed: %v", err)
+	}
+
+	got := dst.nodeInfo(nodeName)
+	// deepEqualWithoutGeneration zeroes only got's generation, and
+	// buildNodeInfo counts one per simulated SetNode/AddPod.
+	expected.generation = 0
+	deepEqualWithoutGeneration(t, 0, got, expected)
+}
+
+// TestSnapshotRestorePreservesPDBs tests that a cluster-scoped PDB and a
+// node PDB both survive a Snapshot followed by a RestoreSnapshot into a
+// fresh cache - the window a warm restart is most likely to matter for a
+// node-draining workflow.
+func TestSnapshotRestorePreservesPDBs(t *testing.T) {
+	nodeName := "test-node"
+	pod := makeBasePod(t, nodeName, "pod1", "100m", "500", "", nil)
+	clusterPDB := makePDB("cluster-pdb", pod.Namespace, nil, 1)
+	nodePDB := makeNodePDB(1)
+
+	src := newSchedulerCache(testContext(), time.Second, time.Second)
+	if err := src.AddPod(testContext(), pod); err != nil {
+		t.Fatalf("AddPod failed: %v", err)
+	}
+	if err := src.AddPDB(testContext(), clusterPDB); err != nil {
+		t.Fatalf("AddPDB failed: %v", err)
+	}
+	if err := src.AddNodePDB(testContext(), nodeName, nodePDB); err != nil {
+		t.Fatalf("AddNodePDB failed: %v", err)
+	}
+
+	snap, err := src.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+
+	dst := newSchedulerCache(testContext(), time.Second, time.Second)
+	if err := dst.RestoreSnapshot(testContext(), snap); err != nil {
+		t.Fatalf("RestoreSnapshot failed: %v", err)
+	}
+
+	pdbs, err := dst.ListPDBs(labels.Everything())
+	if err != nil {
+		t.Fatalf("ListPDBs failed: %v", err)
+	}
+	if len(pdbs) != 1 || !reflect.DeepEqual(pdbs[0], clusterPDB) {
+		t.Errorf("ListPDBs after restore = %v, want [%v]", pdbs, clusterPDB)
+	}
+	pdbsForPod, err := dst.PDBsForPod(pod)
+	if err != nil {
+		t.Fatalf("PDBsForPod failed: %v", err)
+	}
+	if len(pdbsForPod) != 1 || !reflect.DeepEqual(pdbsForPod[0], clusterPDB) {
+		t.Errorf("PDBsForPod after restore = %v, want [%v]", pdbsForPod, clusterPDB)
+	}
+
+	if got := dst.NodePDBsForNode(nodeName); !reflect.DeepEqual(got, nodePDB) {
+		t.Errorf("NodePDBsForNode(%v) after restore = %v, want %v", nodeName, got, nodePDB)
+	}
+	// 1 pod, MinAvailable 1: restored node PDB should still constrain
+	// disruptions rather than silently reporting unconstrained (-1).
+	if got := dst.AllowedDisruptions(nodeName); got != 0 {
+		t.Errorf("AllowedDisruptions(%v) after restore = %v, want 0", nodeName, got)
+	}
+}
+
+// TestSnapshotRestorePreservesRemainingTTL tests that an assumed pod close
+// to expiry at snapshot time still expires at roughly the same wall-clock
+// offset after being restored into a fresh cache.
+func TestSnapshotRestorePreservesRemainingTTL(t *testing.T) {
+	nodeName := "node"
+	ttl := 10 * time.Second
+	pod := makeBasePod(t, nodeName, "test-1", "100m", "500", "", []v1.ContainerPort{{HostIP: "127.0.0.1", HostPort: 80, Protocol: "TCP"}})
+
+	assumedAt := time.Now()
+	src := newSchedulerCache(testContext(), ttl, time.Second)
+	if err := assumeAndFinishBinding(src, pod, assumedAt); err != nil {
+		t.Fatalf("assumePod failed: %v", err)
+	}
+
+	// Take the snapshot when the pod has 4s of TTL left.
+	snapshotTime := assumedAt.Add(6 * time.Second)
+	snap, err := src.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	snap.TakenAt = snapshotTime
+
+	dst := newSchedulerCache(testContext(), ttl, time.Second)
+	restoreTime := snapshotTime.Add(time.Second)
+	if err := dst.restoreSnapshot(snap, restoreTime); err != nil {
+		t.Fatalf("restoreSnapshot failed: %v", err)
+	}
+
+	// The 4s left at snapshot time are re-anchored to restoreTime: not yet
+	// expired just after restore...
+	dst.cleanupAssumedPods(testContext(), restoreTime.Add(1 * time.Second))
+	if n := dst.nodeInfo(nodeName); n == nil {
+		t.Fatalf("expected the restored pod to survive a cleanup sweep within its remaining TTL")
+	}
+
+	// ...but expired once the remaining TTL has elapsed.
+	dst.cleanupAssumedPods(testContext(), restoreTime.Add(5 * time.Second))
+	if n := dst.nodeInfo(nodeName); n != nil {
+		t.Errorf("expected the restored pod to expire once its remaining TTL elapsed, got %s", n)
+	}
+}